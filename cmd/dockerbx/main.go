@@ -26,9 +26,20 @@ func main() {
 	rootCmd.AddCommand(commands.BuildCmd())
 	rootCmd.AddCommand(commands.PythonCmd())
 	rootCmd.AddCommand(commands.EnterCmd())
+	rootCmd.AddCommand(commands.SessionsCmd())
+	rootCmd.AddCommand(commands.ReplayCmd())
+	rootCmd.AddCommand(commands.StartCmd())
+	rootCmd.AddCommand(commands.StopCmd())
+	rootCmd.AddCommand(commands.RestartCmd())
 	rootCmd.AddCommand(commands.ListCmd())
+	rootCmd.AddCommand(commands.StatsCmd())
+	rootCmd.AddCommand(commands.InspectCmd())
 	rootCmd.AddCommand(commands.RemoveCmd())
+	rootCmd.AddCommand(commands.PruneCmd())
 	rootCmd.AddCommand(commands.RunCmd())
+	rootCmd.AddCommand(commands.ExportBinCmd())
+	rootCmd.AddCommand(commands.HostExecAgentCmd())
+	rootCmd.AddCommand(commands.IdleWatcherCmd())
 	rootCmd.AddCommand(commands.UpdateCmd())
 	rootCmd.AddCommand(commands.InitCmd())
 	rootCmd.AddCommand(commands.ExportConfigCmd())
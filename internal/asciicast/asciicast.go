@@ -0,0 +1,178 @@
+// Package asciicast reads and writes terminal recordings in the asciicast v2
+// format used by asciinema.
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+)
+
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event is a single line of the recording: the seconds elapsed since the
+// start, the event type and its data.
+type Event struct {
+	Time float64
+	Type string
+	Data string
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, e.Type, e.Data})
+}
+
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("asciicast: event has %d fields, want 3", len(fields))
+	}
+	if err := json.Unmarshal(fields[0], &e.Time); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[1], &e.Type); err != nil {
+		return err
+	}
+	return json.Unmarshal(fields[2], &e.Data)
+}
+
+// Recorder writes an asciicast v2 recording. It is an io.Writer so it can be
+// teed with the output stream, and it is safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	start   time.Time
+	pending []byte
+}
+
+// NewRecorder writes the header of a recording for a terminal of the given
+// size and returns a Recorder for its events.
+func NewRecorder(w io.Writer, width, height int, title string, env map[string]string) (*Recorder, error) {
+	start := time.Now()
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	err := enc.Encode(Header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: start.Unix(),
+		Title:     title,
+		Env:       env,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{enc: enc, start: start}, nil
+}
+
+// Write records p as output. A multi-byte character split across writes is
+// held back until it is complete, since events must be valid UTF-8.
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf := append(r.pending, p...)
+	n := len(buf)
+	for i := n - 1; i >= 0 && i >= n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(buf[i]) {
+			if !utf8.FullRune(buf[i:]) {
+				n = i
+			}
+			break
+		}
+	}
+	r.pending = append([]byte(nil), buf[n:]...)
+
+	if n > 0 {
+		if err := r.event(EventOutput, string(buf[:n])); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Resize records a change of the terminal size.
+func (r *Recorder) Resize(width, height int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.event(EventResize, fmt.Sprintf("%dx%d", width, height))
+}
+
+func (r *Recorder) event(eventType string, data string) error {
+	return r.enc.Encode(Event{
+		Time: time.Since(r.start).Seconds(),
+		Type: eventType,
+		Data: data,
+	})
+}
+
+// Reader reads the events of a recording.
+type Reader struct {
+	scanner *bufio.Scanner
+}
+
+// NewReader reads the header of a recording and returns a Reader for its
+// events.
+func NewReader(r io.Reader) (*Header, *Reader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("asciicast: empty recording")
+	}
+
+	var header Header
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, nil, fmt.Errorf("asciicast: invalid header: %w", err)
+	}
+	if header.Version != 2 {
+		return nil, nil, fmt.Errorf("asciicast: unsupported version %d", header.Version)
+	}
+
+	return &header, &Reader{scanner: scanner}, nil
+}
+
+// Next returns the next event, or io.EOF at the end of the recording.
+func (r *Reader) Next() (Event, error) {
+	for r.scanner.Scan() {
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return Event{}, fmt.Errorf("asciicast: invalid event: %w", err)
+		}
+		return event, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
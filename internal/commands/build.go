@@ -49,6 +49,8 @@ func runBuild(cmd *cobra.Command, args []string) {
 		Dockerfile: dockerfile,
 		Tags:       []string{name},
 		Remove:     true,
+		// the label lets dockerbx prune find the images it built
+		Labels: map[string]string{"owned_by": "dockerbx"},
 	})
 	if err != nil {
 		fmt.Printf("Error building image: %v\n", err)
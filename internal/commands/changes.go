@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// volatilePaths hold state that is not worth keeping across an update, or
+// files Docker manages itself.
+var volatilePaths = []string{
+	"/tmp",
+	"/var/tmp",
+	"/run",
+	"/var/run",
+	"/var/cache",
+	"/var/log",
+	"/dev",
+	"/proc",
+	"/sys",
+	"/etc/hostname",
+	"/etc/hosts",
+	"/etc/resolv.conf",
+}
+
+// deleteScript removes the paths listed one per line in $DOCKERBX_DELETED.
+const deleteScript = `
+printf '%s\n' "$DOCKERBX_DELETED" | while IFS= read -r p; do
+	[ -n "$p" ] && rm -rf -- "$p"
+done
+`
+
+// changeArchive holds the changes made in a container's filesystem, ready to
+// be copied into another container.
+type changeArchive struct {
+	// File is a tar archive of the added and modified files, rooted at /.
+	File *os.File
+	// Copied is the number of paths in the archive.
+	Copied int
+	// Deleted lists the paths removed from the image.
+	Deleted []string
+	// Skipped lists the changes that cannot be carried over, with the
+	// reason.
+	Skipped []string
+}
+
+// Close removes the temporary archive.
+func (a *changeArchive) Close() {
+	a.File.Close()
+	os.Remove(a.File.Name())
+}
+
+// containerChanges returns the changes made to the container's filesystem
+// outside the volatile paths and the mounts, parents first.
+func containerChanges(ctx context.Context, cli *client.Client, containerJSON types.ContainerJSON) ([]container.FilesystemChange, error) {
+	changes, err := cli.ContainerDiff(ctx, containerJSON.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := filterChanges(changes, containerJSON.Mounts)
+	sortChanges(kept)
+	return kept, nil
+}
+
+// filterChanges drops the changes in volatile paths and those at or under a
+// mount destination. Docker creates missing mount targets in the writable
+// layer, so the diff reports them as added, but their contents live on the
+// host or in a volume and must not be copied.
+func filterChanges(changes []container.FilesystemChange, mounts []types.MountPoint) []container.FilesystemChange {
+	var kept []container.FilesystemChange
+	for _, change := range changes {
+		if !isVolatilePath(change.Path) && !isMountPath(change.Path, mounts) {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+func isVolatilePath(p string) bool {
+	for _, volatile := range volatilePaths {
+		if p == volatile || strings.HasPrefix(p, volatile+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isMountPath(p string, mounts []types.MountPoint) bool {
+	for _, m := range mounts {
+		destination := strings.TrimSuffix(m.Destination, "/")
+		if destination != "" && (p == destination || strings.HasPrefix(p, destination+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsMount reports whether a mount destination lies under the
+// directory, in which case copying the directory would walk into the mount.
+func containsMount(dir string, mounts []types.MountPoint) bool {
+	for _, m := range mounts {
+		if strings.HasPrefix(m.Destination, strings.TrimSuffix(dir, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// sortChanges orders the changes by path component, so that every
+// directory is directly followed by its contents: "/a", "/a/b", "/a-b".
+// Plain string order would put "/a-b" before "/a/b".
+func sortChanges(changes []container.FilesystemChange) {
+	key := func(p string) string { return strings.ReplaceAll(p, "/", "\x00") }
+	sort.Slice(changes, func(i, j int) bool { return key(changes[i].Path) < key(changes[j].Path) })
+}
+
+// exportChanges copies the added and modified files out of the container
+// into a temporary archive. Modified directories are not copied as a whole,
+// since that would bring back the old versions of files the new image
+// updates; only their changed entries are, which the diff lists separately.
+// The same goes for added directories holding a mount, such as /home when
+// only the home directory is mounted.
+func exportChanges(ctx context.Context, cli *client.Client, containerJSON types.ContainerJSON, changes []container.FilesystemChange) (*changeArchive, error) {
+	containerID := containerJSON.ID
+	file, err := os.CreateTemp("", "dockerbx-changes-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	archive := &changeArchive{File: file}
+	writer := tar.NewWriter(file)
+
+	addedDir := ""
+	for _, change := range changes {
+		// everything under an added directory comes with it
+		if addedDir != "" && strings.HasPrefix(change.Path, addedDir+"/") {
+			continue
+		}
+		addedDir = ""
+
+		if change.Kind == container.ChangeDelete {
+			archive.Deleted = append(archive.Deleted, change.Path)
+			continue
+		}
+
+		stat, err := cli.ContainerStatPath(ctx, containerID, change.Path)
+		if err != nil {
+			archive.Skipped = append(archive.Skipped, fmt.Sprintf("%s: %v", change.Path, err))
+			continue
+		}
+		switch {
+		case stat.Mode.IsDir() && (change.Kind == container.ChangeModify || containsMount(change.Path, containerJSON.Mounts)):
+			continue
+		case stat.Mode&(os.ModeSocket|os.ModeDevice|os.ModeNamedPipe|os.ModeCharDevice) != 0:
+			archive.Skipped = append(archive.Skipped, fmt.Sprintf("%s: special file", change.Path))
+			continue
+		case stat.Mode.IsDir():
+			addedDir = change.Path
+		}
+
+		if err := copyChange(ctx, cli, containerID, change.Path, writer); err != nil {
+			archive.Skipped = append(archive.Skipped, fmt.Sprintf("%s: %v", change.Path, err))
+			continue
+		}
+		archive.Copied++
+	}
+
+	if err := writer.Close(); err != nil {
+		archive.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		archive.Close()
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+// copyChange appends the path from the container to the archive, renaming
+// the entries Docker returns relative to the path's parent so that the
+// archive is rooted at /.
+func copyChange(ctx context.Context, cli *client.Client, containerID string, p string, writer *tar.Writer) error {
+	reader, _, err := cli.CopyFromContainer(ctx, containerID, p)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	parent := strings.TrimPrefix(path.Dir(p), "/")
+	entries := tar.NewReader(reader)
+	for {
+		header, err := entries.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		header.Name = path.Join(parent, header.Name)
+		if header.Typeflag == tar.TypeDir {
+			header.Name += "/"
+		}
+		if header.Typeflag == tar.TypeLink {
+			header.Linkname = path.Join(parent, header.Linkname)
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(writer, entries); err != nil {
+			return err
+		}
+	}
+}
+
+// importChanges copies the archived files into the container, which may be
+// stopped, keeping their owners.
+func importChanges(ctx context.Context, cli *client.Client, containerID string, archive *changeArchive) error {
+	if archive.Copied == 0 {
+		return nil
+	}
+	return cli.CopyToContainer(ctx, containerID, "/", archive.File, container.CopyToContainerOptions{
+		AllowOverwriteDirWithFile: true,
+		CopyUIDGID:                true,
+	})
+}
+
+// applyDeletions removes the deleted paths from the container, which must
+// be running.
+func applyDeletions(ctx context.Context, cli *client.Client, containerID string, archive *changeArchive) error {
+	if len(archive.Deleted) == 0 {
+		return nil
+	}
+	return runSetupScript(ctx, cli, containerID, deleteScript, []string{
+		"DOCKERBX_DELETED=" + strings.Join(archive.Deleted, "\n"),
+	})
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestSortChanges(t *testing.T) {
+	var changes []container.FilesystemChange
+	for _, p := range []string{"/a-b", "/a/b/c", "/a.txt", "/a", "/b", "/a/b", "/a/b-c", "/a0"} {
+		changes = append(changes, container.FilesystemChange{Path: p})
+	}
+	sortChanges(changes)
+
+	var got []string
+	for _, change := range changes {
+		got = append(got, change.Path)
+	}
+	want := []string{"/a", "/a/b", "/a/b/c", "/a/b-c", "/a-b", "/a.txt", "/a0", "/b"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFilterChanges(t *testing.T) {
+	mounts := []types.MountPoint{
+		{Type: "bind", Source: "/home/user", Destination: "/home/user"},
+		{Type: "bind", Source: "/run/user/dockerbx", Destination: "/run/dockerbx/"},
+		{Type: "volume", Name: "cache", Destination: "/srv/cache"},
+	}
+
+	var changes []container.FilesystemChange
+	for _, p := range []string{
+		"/home", "/home/user", "/home/user/.bashrc", "/home/username", "/home/other/file",
+		"/srv", "/srv/cache", "/srv/cache/db", "/srv/cached",
+		"/tmp/build", "/var/cache/dnf", "/usr/local/bin/tool", "/run/dockerbx",
+	} {
+		changes = append(changes, container.FilesystemChange{Kind: container.ChangeAdd, Path: p})
+	}
+
+	var got []string
+	for _, change := range filterChanges(changes, mounts) {
+		got = append(got, change.Path)
+	}
+	want := []string{"/home", "/home/username", "/home/other/file", "/srv", "/srv/cached", "/usr/local/bin/tool"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for dir, holds := range map[string]bool{"/home": true, "/home/user": false, "/srv": true, "/srv/cached": false, "/usr": false} {
+		if containsMount(dir, mounts) != holds {
+			t.Errorf("containsMount(%q) = %v, want %v", dir, !holds, holds)
+		}
+	}
+}
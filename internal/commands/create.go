@@ -22,6 +22,8 @@ func CreateCmd() *cobra.Command {
 
 	cmd.Flags().String("clone", "", "Git repository URL to clone")
 	cmd.Flags().String("image", "", "Image to use, default is in the config")
+	cmd.Flags().String("profile", "", "Configuration profile to use for the container")
+	cmd.Flags().String("shell", "", "Shell to start on enter, recorded on the container; default is the profile's, else detected on each enter")
 
 	return cmd
 }
@@ -57,24 +59,55 @@ func runCreate(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	labels := map[string]string{
+		"owned_by": "dockerbx",
+	}
+	profile, _ := cmd.Flags().GetString("profile")
+	if profile != "" {
+		labels["profile"] = profile
+	}
+	shell, _ := cmd.Flags().GetString("shell")
+	if shell == "" {
+		shell = config.Profile(profile).Shell
+	}
+	// only an explicit choice is recorded, a detected shell is detected
+	// again on each enter
+	if shell != "" {
+		labels["shell"] = shell
+	}
+	if flags := createFlags(cmd); flags != "" {
+		labels[createFlagsLabel] = flags
+	}
+
+	mounts := []mount.Mount{
+		{
+			Type:   mount.TypeBind,
+			Source: config.Mounts[0].Source,
+			Target: config.Mounts[0].Target,
+		},
+	}
+	// the bridge is optional, so a socket directory that cannot be
+	// prepared only leaves dockerbx-host-exec unusable
+	var bridgeErr error
+	if len(config.HostExec.Allow) > 0 {
+		bridgeMount, err := hostExecMount()
+		if err != nil {
+			bridgeErr = fmt.Errorf("preparing the socket directory: %w", err)
+		} else {
+			mounts = append(mounts, bridgeMount)
+		}
+	}
+
 	resp, err := cli.ContainerCreate(ctx,
 		&container.Config{
-			Image: baseImage,
-			Cmd:   []string{"/bin/bash"},
-			Tty:   true,
-			Labels: map[string]string{
-				"owned_by": "dockerbx",
-			},
-			Env: []string{"PS1=\\[\\e[32m\\]⬢\\[\\e[0m\\][\\u@dockerbx](\\W)\\$ "},
+			Image:  baseImage,
+			Cmd:    []string{"/bin/bash"},
+			Tty:    true,
+			Labels: labels,
+			Env:    append([]string{"PS1=\\[\\e[32m\\]⬢\\[\\e[0m\\][\\u@dockerbx](\\W)\\$ "}, timezoneAndLocaleEnv(config)...),
 		},
 		&container.HostConfig{
-			Mounts: []mount.Mount{
-				{
-					Type:   mount.TypeBind,
-					Source: config.Mounts[0].Source,
-					Target: config.Mounts[0].Target,
-				},
-			},
+			Mounts: mounts,
 		},
 		nil,
 		nil,
@@ -94,6 +127,26 @@ func runCreate(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("Container %s is running\n", containerName)
 
+	provisioning := &provisionRecord{}
+	defer saveProvisioning(ctx, cli, resp.ID, provisioning)
+
+	err = setupTimezoneAndLocale(ctx, cli, resp.ID, config)
+	provisioning.add("timezone-locale", err)
+	if err != nil {
+		fmt.Printf("Warning: could not set up timezone and locale: %v\n", err)
+	}
+
+	if len(config.HostExec.Allow) > 0 {
+		err = bridgeErr
+		if err == nil {
+			err = setupHostExec(ctx, cli, resp.ID)
+		}
+		provisioning.add("host-exec", err)
+		if err != nil {
+			fmt.Printf("Warning: could not set up dockerbx-host-exec: %v\n", err)
+		}
+	}
+
 	cloneURL, _ := cmd.Flags().GetString("clone")
 	if cloneURL != "" {
 		cloneCmd := fmt.Sprintf("git clone %s /app", cloneURL)
@@ -101,13 +154,16 @@ func runCreate(cmd *cobra.Command, args []string) {
 			Cmd: []string{"/bin/sh", "-c", cloneCmd},
 		})
 		if err != nil {
+			provisioning.add("clone", err)
 			fmt.Printf("Error creating clone command: %v\n", err)
 			return
 		}
 		if err := cli.ContainerExecStart(ctx, execResp.ID, types.ExecStartCheck{}); err != nil {
+			provisioning.add("clone", err)
 			fmt.Printf("Error starting clone: %v\n", err)
 			return
 		}
+		provisioning.started("clone")
 		fmt.Printf("Repository cloned: %s\n", cloneURL)
 	}
 }
@@ -3,55 +3,77 @@ package commands
 import (
 	"context"
 	"fmt"
-	"io"
 	"os"
+	"path"
+	"strings"
 
+	"github.com/albertoperdomo2/dockerbx/internal/asciicast"
 	"github.com/albertoperdomo2/dockerbx/internal/config"
+	"github.com/albertoperdomo2/dockerbx/internal/hostexec"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	mobyterm "github.com/moby/term"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
 func EnterCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "enter [container_name]",
+	cmd := &cobra.Command{
+		Use:   "enter [container_name] [-- command...]",
 		Short: "Enter an existing container",
 		Run:   runEnter,
 	}
+
+	cmd.Flags().StringP("workdir", "w", "", "Working directory inside the container, default matches the host directory")
+	cmd.Flags().String("shell", "", "Shell to start, default is the container's recorded shell or detected")
+	cmd.Flags().StringP("user", "u", "", "User to run as inside the container (name|uid[:group|gid])")
+	cmd.Flags().StringArrayP("env", "e", []string{}, "Set environment variables (KEY=value, or KEY to copy it from the host)")
+	cmd.Flags().String("session", "", "Start or reattach to a named persistent session")
+	cmd.Flags().String("detach-keys", "", "Key sequence to detach from the container, default is ctrl-p,ctrl-q")
+	cmd.Flags().String("record", "", "Record the session to an asciicast v2 file")
+
+	return cmd
 }
 
 func runEnter(cmd *cobra.Command, args []string) {
+	os.Exit(enterContainer(cmd, args))
+}
+
+// enterContainer runs the session and returns the exit code for dockerbx,
+// so that the deferred cleanup runs before the process exits.
+func enterContainer(cmd *cobra.Command, args []string) int {
 	ctx := context.Background()
 
 	config, err := config.LoadConfig()
 	if err != nil {
 		fmt.Printf("Error loading config: %v\n", err)
-		return
+		return 1
 	}
 
-	containerName := config.DefaultName
-	if len(args) > 0 {
-		containerName = args[0]
+	// anything after "--" is the command to run instead of the shell
+	var command []string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		command = args[dash:]
+		args = args[:dash]
 	}
 
 	cli, err := client.NewClientWithOpts(client.FromEnv)
 	if err != nil {
 		fmt.Printf("Error creating Docker client: %v\n", err)
-		return
+		return 1
 	}
 
-	_, err = cli.ContainerInspect(ctx, containerName)
+	containerName, err := resolveContainerName(ctx, cli, args, config.DefaultName)
 	if err != nil {
-		fmt.Printf("Container %s does not exist. Please create it first.\n", containerName)
-		return
+		fmt.Printf("Error: %v\n", err)
+		return 1
 	}
 
 	containerJSON, err := cli.ContainerInspect(ctx, containerName)
 	if err != nil {
 		fmt.Printf("Error inspecting container: %v\n", err)
-		return
+		return 1
 	}
 
 	if !containerJSON.State.Running {
@@ -59,8 +81,71 @@ func runEnter(cmd *cobra.Command, args []string) {
 		err = cli.ContainerStart(ctx, containerName, container.StartOptions{})
 		if err != nil {
 			fmt.Printf("Error starting container: %v\n", err)
-			return
+			return 1
+		}
+	}
+	ensureIdleWatcher(config)
+
+	// serve dockerbx-host-exec for the duration of the session, unless a
+	// host-exec-agent or another session already does
+	if len(config.HostExec.Allow) > 0 {
+		if stop, err := startHostExecBridge(config, nil); err == nil {
+			defer stop()
+		} else if err != hostexec.ErrListening {
+			fmt.Printf("Warning: could not start host-exec bridge: %v\n", err)
+		}
+	}
+
+	user, _ := cmd.Flags().GetString("user")
+	envFlags, _ := cmd.Flags().GetStringArray("env")
+	workdirOverride, _ := cmd.Flags().GetString("workdir")
+	workdir := resolveWorkdir(ctx, cli, containerJSON, user, workdirOverride)
+
+	if len(command) == 0 {
+		preferredShell, _ := cmd.Flags().GetString("shell")
+		if preferredShell == "" {
+			preferredShell = containerJSON.Config.Labels["shell"]
+		}
+		if preferredShell == "" {
+			preferredShell = config.Profile(containerJSON.Config.Labels["profile"]).Shell
+		}
+		var shell string
+		shell, command = detectShell(ctx, cli, containerJSON.ID, user, preferredShell)
+		if preferredShell != "" && path.Base(shell) != path.Base(preferredShell) {
+			fmt.Printf("Shell %s not found in container %s, using %s\n", preferredShell, containerName, shell)
+		}
+	}
+
+	// without a terminal on stdin (e.g. when piping) run the shell without a
+	// TTY so that its output is not mangled by terminal line discipline
+	tty := term.IsTerminal(int(os.Stdin.Fd()))
+
+	detachKeys, _ := cmd.Flags().GetString("detach-keys")
+	if detachKeys == "" {
+		detachKeys = config.DetachKeys
+	}
+	if detachKeys == "" {
+		detachKeys = defaultDetachKeys
+	}
+	detachSequence, err := mobyterm.ToBytes(detachKeys)
+	if err != nil {
+		fmt.Printf("Error: Invalid detach keys %q: %v\n", detachKeys, err)
+		return 1
+	}
+
+	env := envVars(envFlags)
+	sessionName, _ := cmd.Flags().GetString("session")
+	if sessionName != "" {
+		if !tty {
+			fmt.Println("Error: Sessions need a terminal on stdin.")
+			return 1
+		}
+		if err := ensureSessionSupport(ctx, cli, containerJSON.ID); err != nil {
+			fmt.Printf("Error setting up sessions: %v\n", err)
+			return 1
 		}
+		command = sessionCommand(sessionName, workdir, env, command)
+		fmt.Printf("Attaching to session %s, detach with Ctrl-b d\n", sessionName)
 	}
 
 	// exec default config
@@ -68,55 +153,63 @@ func runEnter(cmd *cobra.Command, args []string) {
 		AttachStdin:  true,
 		AttachStdout: true,
 		AttachStderr: true,
-		Tty:          true,
-		Cmd:          []string{"/bin/bash", "--rcfile", "/etc/bashrc"},
+		Tty:          tty,
+		WorkingDir:   workdir,
+		User:         user,
+		Env:          append(terminalEnv(), env...),
+		Cmd:          command,
+		DetachKeys:   detachKeys,
+	}
+	if tty {
+		execConfig.ConsoleSize = terminalSize()
 	}
 
 	execID, err := cli.ContainerExecCreate(ctx, containerName, execConfig)
 	if err != nil {
 		fmt.Printf("Error creating exec instance: %v\n", err)
-		return
-	}
-
-	resp, err := cli.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{Tty: true})
-	if err != nil {
-		fmt.Printf("Error attaching to exec instance: %v\n", err)
-		return
+		return 1
 	}
-	defer resp.Close()
 
-	// setup terminal
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
-	if err != nil {
-		fmt.Printf("Error setting up terminal: %v\n", err)
-		return
+	opts := attachOptions{
+		Tty:         tty,
+		ConsoleSize: execConfig.ConsoleSize,
+		Stdin:       true,
+		DetachKeys:  detachSequence,
 	}
-	defer term.Restore(int(os.Stdin.Fd()), oldState)
 
-	err = cli.ContainerExecStart(ctx, execID.ID, types.ExecStartCheck{Tty: true})
-	if err != nil {
-		fmt.Printf("Error starting exec instance: %v\n", err)
-		return
-	}
-
-	// handle I/O
-	go func() {
-		io.Copy(os.Stdout, resp.Reader)
-	}()
-	go func() {
-		io.Copy(resp.Conn, os.Stdin)
-	}()
-
-	// wait for exec instance to finish
-	for {
-		inspectResp, err := cli.ContainerExecInspect(ctx, execID.ID)
+	recordPath, _ := cmd.Flags().GetString("record")
+	if recordPath != "" {
+		recordFile, err := os.Create(recordPath)
 		if err != nil {
-			fmt.Printf("Error inspecting exec instance: %v\n", err)
-			return
+			fmt.Printf("Error creating recording file: %v\n", err)
+			return 1
 		}
+		defer recordFile.Close()
 
-		if !inspectResp.Running {
-			break
+		width, height := 80, 24
+		if execConfig.ConsoleSize != nil {
+			height, width = int(execConfig.ConsoleSize[0]), int(execConfig.ConsoleSize[1])
 		}
+		opts.Recorder, err = asciicast.NewRecorder(recordFile, width, height, containerName, map[string]string{
+			"SHELL": command[0],
+			"TERM":  strings.TrimPrefix(terminalEnv()[0], "TERM="),
+		})
+		if err != nil {
+			fmt.Printf("Error starting recording: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Recording session to %s\n", recordPath)
 	}
+
+	exitCode, err := attachExec(ctx, cli, execID.ID, opts)
+	if err == errDetached {
+		fmt.Printf("\nDetached from %s, %s keeps running.\n", containerName, command[0])
+		return 0
+	}
+	if err != nil {
+		fmt.Printf("Error running %s: %v\n", command[0], err)
+		return 1
+	}
+
+	return exitCode
 }
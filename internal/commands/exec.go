@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// execInContainer runs execConfig inside the container, waits for it to
+// finish and returns its exit code together with the combined output.
+func execInContainer(ctx context.Context, cli *client.Client, containerID string, execConfig types.ExecConfig) (int, string, error) {
+	execConfig.AttachStdout = true
+	execConfig.AttachStderr = true
+	execResp, err := cli.ContainerExecCreate(ctx, containerID, execConfig)
+	if err != nil {
+		return 0, "", err
+	}
+
+	resp, err := cli.ContainerExecAttach(ctx, execResp.ID, types.ExecStartCheck{})
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Close()
+
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, resp.Reader); err != nil {
+		return 0, output.String(), err
+	}
+
+	inspectResp, err := cli.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return 0, output.String(), err
+	}
+
+	return inspectResp.ExitCode, strings.TrimSpace(output.String()), nil
+}
+
+// runSetupScript runs a shell script as root as part of container
+// provisioning and turns a non-zero exit into an error carrying the script
+// output.
+func runSetupScript(ctx context.Context, cli *client.Client, containerID string, script string, env []string) error {
+	exitCode, output, err := execInContainer(ctx, cli, containerID, types.ExecConfig{
+		User: "root",
+		Cmd:  []string{"/bin/sh", "-c", script},
+		Env:  env,
+	})
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("exit status %d: %s", exitCode, output)
+	}
+	return nil
+}
+
+// envVars turns --env values into KEY=value pairs. A bare KEY copies the
+// variable from the host environment and is dropped when the host does not
+// have it.
+func envVars(values []string) []string {
+	var env []string
+	for _, value := range values {
+		if strings.Contains(value, "=") {
+			env = append(env, value)
+		} else if hostValue, ok := os.LookupEnv(value); ok {
+			env = append(env, value+"="+hostValue)
+		}
+	}
+	return env
+}
+
+// installPackageScript returns shell code that installs the packages with
+// whichever of dnf, apt-get and apk the image has. The names are inserted
+// as is, so they may refer to shell variables.
+func installPackageScript(pkgs ...string) string {
+	names := strings.Join(pkgs, " ")
+	return `
+if command -v dnf >/dev/null 2>&1; then
+	dnf install -y ` + names + `
+elif command -v apt-get >/dev/null 2>&1; then
+	apt-get update && DEBIAN_FRONTEND=noninteractive apt-get install -y ` + names + `
+elif command -v apk >/dev/null 2>&1; then
+	apk add --no-cache ` + names + `
+fi
+`
+}
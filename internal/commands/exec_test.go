@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestSetupScriptsParse(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	scripts := map[string]string{
+		"timezone": timezoneSetupScript,
+		"locale":   localeSetupScript,
+		"session":  sessionSetupScript,
+		"hostexec": hostExecSetupScript,
+	}
+	for name, script := range scripts {
+		if output, err := exec.Command("sh", "-n", "-c", script).CombinedOutput(); err != nil {
+			t.Errorf("%s: %v\n%s", name, err, output)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/albertoperdomo2/dockerbx/internal/config"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+)
+
+// shimMarker identifies the scripts written by export-bin, so that other
+// files are never overwritten or deleted.
+const shimMarker = "# Generated by dockerbx export-bin, do not edit."
+
+func ExportBinCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export-bin [container_name] [binary...]",
+		Short: "Make container binaries available as host commands",
+		Long: `Create a shim script on the host for each binary, which runs it inside the
+container with dockerbx run, in the container directory matching the current
+host directory.
+
+  dockerbx export-bin my-env /usr/bin/rg
+  dockerbx export-bin --list
+  dockerbx export-bin --remove my-env /usr/bin/rg`,
+		Run: runExportBin,
+	}
+
+	cmd.Flags().String("dir", "", "Directory for the shims, default is ~/.local/bin")
+	cmd.Flags().Bool("list", false, "List exported binaries")
+	cmd.Flags().Bool("remove", false, "Remove exported binaries, all of the container's if none are given")
+
+	return cmd
+}
+
+func runExportBin(cmd *cobra.Command, args []string) {
+	list, _ := cmd.Flags().GetBool("list")
+	remove, _ := cmd.Flags().GetBool("remove")
+
+	switch {
+	case list:
+		listExports(args)
+	case remove:
+		if len(args) == 0 {
+			fmt.Println("Error: Please provide the container whose binaries to remove.")
+			return
+		}
+		removed, err := removeExports(args[0], args[1:])
+		for _, export := range removed {
+			fmt.Printf("Removed %s (%s from %s)\n", export.Shim, export.Binary, export.Container)
+		}
+		if err != nil {
+			fmt.Printf("Error removing exported binaries: %v\n", err)
+			return
+		}
+		if len(removed) == 0 {
+			fmt.Println("No exported binaries matched.")
+		}
+	default:
+		if len(args) < 2 {
+			fmt.Println("Error: Please provide a container name and at least one binary to export.")
+			return
+		}
+		dir, _ := cmd.Flags().GetString("dir")
+		exportBinaries(args[0], args[1:], dir)
+	}
+}
+
+func exportBinaries(containerName string, binaries []string, dir string) {
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		fmt.Printf("Error creating Docker client: %v\n", err)
+		return
+	}
+
+	containerJSON, err := cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		fmt.Printf("Error: Container '%s' not found.\n", containerName)
+		return
+	}
+	containerName = strings.TrimPrefix(containerJSON.Name, "/")
+
+	if !containerJSON.State.Running {
+		fmt.Printf("Container '%s' is not running. Starting it now...\n", containerName)
+		if err := cli.ContainerStart(ctx, containerJSON.ID, container.StartOptions{}); err != nil {
+			fmt.Printf("Error starting container: %v\n", err)
+			return
+		}
+	}
+
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			fmt.Printf("Error getting user home directory: %v\n", err)
+			return
+		}
+		dir = filepath.Join(homeDir, ".local", "bin")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("Error creating shim directory: %v\n", err)
+		return
+	}
+
+	dockerbxPath, err := os.Executable()
+	if err != nil {
+		dockerbxPath = "dockerbx"
+	}
+
+	exports, err := config.LoadExports()
+	if err != nil {
+		fmt.Printf("Error loading exported binaries: %v\n", err)
+		return
+	}
+
+	for _, binary := range binaries {
+		exitCode, output, err := execInContainer(ctx, cli, containerJSON.ID, types.ExecConfig{
+			Cmd: []string{"/bin/sh", "-c", `command -v "$0"`, binary},
+		})
+		if err != nil || exitCode != 0 {
+			fmt.Printf("Error: %s not found in container '%s'.\n", binary, containerName)
+			continue
+		}
+		binaryPath := strings.TrimSpace(output)
+
+		shim := filepath.Join(dir, path.Base(binaryPath))
+		if existing, err := os.ReadFile(shim); err == nil && !strings.Contains(string(existing), shimMarker) {
+			fmt.Printf("Error: %s already exists and was not created by dockerbx.\n", shim)
+			continue
+		}
+
+		if err := os.WriteFile(shim, []byte(shimScript(dockerbxPath, containerName, binaryPath)), 0755); err != nil {
+			fmt.Printf("Error writing shim: %v\n", err)
+			continue
+		}
+
+		exports = slices.DeleteFunc(exports, func(e config.Export) bool { return e.Shim == shim })
+		exports = append(exports, config.Export{Container: containerName, Binary: binaryPath, Shim: shim})
+		fmt.Printf("Exported %s from %s as %s\n", binaryPath, containerName, shim)
+	}
+
+	if err := config.SaveExports(exports); err != nil {
+		fmt.Printf("Error saving exported binaries: %v\n", err)
+	}
+}
+
+func listExports(args []string) {
+	exports, err := config.LoadExports()
+	if err != nil {
+		fmt.Printf("Error loading exported binaries: %v\n", err)
+		return
+	}
+
+	var matched []config.Export
+	for _, export := range exports {
+		if len(args) == 0 || slices.Contains(args, export.Container) {
+			matched = append(matched, export)
+		}
+	}
+
+	if len(matched) == 0 {
+		fmt.Println("No exported binaries found.")
+		return
+	}
+
+	format := "%-20s%-30s%s\n"
+	fmt.Printf(format, "CONTAINER", "BINARY", "SHIM")
+	for _, export := range matched {
+		fmt.Printf(format, truncateString(export.Container, 20), truncateString(export.Binary, 30), export.Shim)
+	}
+}
+
+// removeExports deletes the shims of the container's exported binaries, or
+// of all of them when binaries is empty, and drops them from the manifest.
+// Shims that cannot be deleted stay in the manifest, and the others are
+// still removed.
+func removeExports(containerName string, binaries []string) ([]config.Export, error) {
+	exports, err := config.LoadExports()
+	if err != nil {
+		return nil, err
+	}
+
+	var kept, removed []config.Export
+	var errs []error
+	for _, export := range exports {
+		matches := export.Container == containerName &&
+			(len(binaries) == 0 || slices.Contains(binaries, export.Binary) || slices.Contains(binaries, path.Base(export.Binary)))
+		if !matches {
+			kept = append(kept, export)
+			continue
+		}
+
+		if data, err := os.ReadFile(export.Shim); err == nil && strings.Contains(string(data), shimMarker) {
+			if err := os.Remove(export.Shim); err != nil {
+				kept = append(kept, export)
+				errs = append(errs, err)
+				continue
+			}
+		}
+		removed = append(removed, export)
+	}
+
+	if len(removed) > 0 {
+		if err := config.SaveExports(kept); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// shimScript returns a script that runs binary inside the container,
+// allocating a TTY when it is used interactively.
+func shimScript(dockerbxPath string, containerName string, binary string) string {
+	return fmt.Sprintf(`#!/bin/sh
+%s
+# container: %s
+# binary: %s
+if [ -t 0 ] && [ -t 1 ]; then
+	exec %s run --tty %s -- %s "$@"
+fi
+exec %s run %s -- %s "$@"
+`, shimMarker, containerName, binary,
+		shellQuote(dockerbxPath), shellQuote(containerName), shellQuote(binary),
+		shellQuote(dockerbxPath), shellQuote(containerName), shellQuote(binary))
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,268 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// fanoutOptions describes a command to run in several containers at once.
+type fanoutOptions struct {
+	Command  []string
+	User     string
+	Workdir  string
+	Env      []string
+	Timeout  time.Duration
+	Parallel int
+}
+
+type fanoutResult struct {
+	Container string
+	ExitCode  int
+	Duration  time.Duration
+	TimedOut  bool
+	Err       error
+}
+
+// selectContainers returns the names of the dockerbx containers matching
+// the selectors: every container with all set, otherwise those carrying all
+// of the labels (and the profile, if given).
+func selectContainers(ctx context.Context, cli *client.Client, all bool, labels []string, profile string) ([]string, error) {
+	args := filters.NewArgs(filters.Arg("label", "owned_by=dockerbx"))
+	if !all {
+		for _, label := range labels {
+			args.Add("label", label)
+		}
+		if profile != "" {
+			args.Add("label", "profile="+profile)
+		}
+	}
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, c := range containers {
+		if len(c.Names) > 0 {
+			names = append(names, strings.TrimPrefix(c.Names[0], "/"))
+		}
+	}
+	return names, nil
+}
+
+// runFanout runs the command in every container using a bounded pool of
+// workers, prefixing each output line with the container name.
+func runFanout(ctx context.Context, cli *client.Client, containerNames []string, opts fanoutOptions) []fanoutResult {
+	nameWidth := 0
+	for _, name := range containerNames {
+		if len(name) > nameWidth {
+			nameWidth = len(name)
+		}
+	}
+
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	var outMu sync.Mutex
+	results := make([]fanoutResult, len(containerNames))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				name := containerNames[i]
+				prefix := fmt.Sprintf("%-*s | ", nameWidth, name)
+				stdout := &prefixWriter{mu: &outMu, w: os.Stdout, prefix: prefix}
+				stderr := &prefixWriter{mu: &outMu, w: os.Stderr, prefix: prefix}
+
+				start := time.Now()
+				exitCode, timedOut, err := runCaptured(ctx, cli, name, opts, stdout, stderr)
+				stdout.Flush()
+				stderr.Flush()
+
+				results[i] = fanoutResult{
+					Container: name,
+					ExitCode:  exitCode,
+					Duration:  time.Since(start),
+					TimedOut:  timedOut,
+					Err:       err,
+				}
+			}
+		}()
+	}
+
+	for i := range containerNames {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// runCaptured runs the command in one container without a TTY, copying its
+// output to stdout and stderr, and returns its exit code.
+func runCaptured(ctx context.Context, cli *client.Client, containerName string, opts fanoutOptions, stdout, stderr io.Writer) (int, bool, error) {
+	containerJSON, err := cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return 0, false, fmt.Errorf("container not found")
+	}
+
+	if !containerJSON.State.Running {
+		if err := cli.ContainerStart(ctx, containerJSON.ID, container.StartOptions{}); err != nil {
+			return 0, false, fmt.Errorf("starting container: %w", err)
+		}
+	}
+
+	marker := newExecMarker()
+	execResp, err := cli.ContainerExecCreate(ctx, containerJSON.ID, types.ExecConfig{
+		Cmd:          opts.Command,
+		User:         opts.User,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          append(append([]string{}, opts.Env...), marker),
+		WorkingDir:   resolveWorkdir(ctx, cli, containerJSON, opts.User, opts.Workdir),
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("creating exec instance: %w", err)
+	}
+
+	resp, err := cli.ContainerExecAttach(ctx, execResp.ID, types.ExecStartCheck{})
+	if err != nil {
+		return 0, false, fmt.Errorf("attaching to exec instance: %w", err)
+	}
+	defer resp.Close()
+
+	stopForwarding := forwardSignals(ctx, cli, containerJSON.ID, execResp.ID, marker)
+	defer stopForwarding()
+
+	var timedOutMu sync.Mutex
+	timedOut := false
+	if opts.Timeout > 0 {
+		timer := time.AfterFunc(opts.Timeout, func() {
+			timedOutMu.Lock()
+			timedOut = true
+			timedOutMu.Unlock()
+			terminateExec(ctx, cli, containerJSON.ID, execResp.ID, marker, stopGracePeriod)
+		})
+		defer timer.Stop()
+	}
+
+	_, copyErr := stdcopy.StdCopy(stdout, stderr, resp.Reader)
+
+	timedOutMu.Lock()
+	defer timedOutMu.Unlock()
+
+	if copyErr != nil && copyErr != io.EOF {
+		if execRunning(ctx, cli, execResp.ID) {
+			signalExec(ctx, cli, containerJSON.ID, marker, "KILL")
+		}
+		return 0, timedOut, fmt.Errorf("streaming output: %w", copyErr)
+	}
+
+	inspectResp, err := cli.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return 0, timedOut, fmt.Errorf("inspecting exec instance: %w", err)
+	}
+
+	return inspectResp.ExitCode, timedOut, nil
+}
+
+// printFanoutSummary prints the exit code and duration of every container
+// and reports whether all of them succeeded.
+func printFanoutSummary(results []fanoutResult) bool {
+	nameWidth := len("CONTAINER") + 2
+	for _, r := range results {
+		if len(r.Container)+2 > nameWidth {
+			nameWidth = len(r.Container) + 2
+		}
+	}
+
+	ok := true
+	format := fmt.Sprintf("%%-%ds%%-12s%%-12s%%s\n", nameWidth)
+	fmt.Println()
+	fmt.Printf(format, "CONTAINER", "EXIT CODE", "DURATION", "STATUS")
+	for _, r := range results {
+		exitCode := fmt.Sprintf("%d", r.ExitCode)
+		status := "ok"
+		switch {
+		case r.Err != nil:
+			exitCode = "-"
+			status = "error: " + r.Err.Error()
+		case r.TimedOut:
+			status = "timed out"
+		case r.ExitCode != 0:
+			status = "failed"
+		}
+		if status != "ok" {
+			ok = false
+		}
+		fmt.Printf(format, r.Container, exitCode, r.Duration.Round(time.Millisecond).String(), status)
+	}
+
+	return ok
+}
+
+// prefixWriter writes complete lines to w with a prefix, holding back a
+// trailing partial line until it is completed or flushed. The mutex is
+// shared between writers so that lines from different containers do not
+// interleave.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+
+	return len(data), nil
+}
+
+// Flush writes out a trailing partial line.
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	line := append(p.buf, '\n')
+	p.buf = nil
+	return p.writeLine(line)
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, err := io.WriteString(p.w, p.prefix+string(line))
+	return err
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/albertoperdomo2/dockerbx/internal/config"
+	"github.com/albertoperdomo2/dockerbx/internal/hostexec"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+)
+
+// hostExecSetupScript installs the dockerbx-host-exec helper from
+// $DOCKERBX_HELPER, together with socat which it talks to the host with.
+var hostExecSetupScript = `
+if ! command -v socat >/dev/null 2>&1; then` + installPackageScript("socat") + `fi
+mkdir -p "$(dirname "$DOCKERBX_HELPER_PATH")"
+printf '%s' "$DOCKERBX_HELPER" > "$DOCKERBX_HELPER_PATH"
+chmod 755 "$DOCKERBX_HELPER_PATH"
+command -v socat >/dev/null 2>&1 || { echo "socat is not available"; exit 1; }
+`
+
+func HostExecAgentCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "host-exec-agent",
+		Short: "Serve dockerbx-host-exec requests from containers",
+		Long: `Run the host side of the dockerbx-host-exec bridge in the foreground, so that
+programs in containers can run the host commands allowed by host_exec.allow in
+the config. dockerbx enter also serves the bridge while a session is open.`,
+		Run: runHostExecAgent,
+	}
+}
+
+func runHostExecAgent(cmd *cobra.Command, args []string) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+
+	if len(cfg.HostExec.Allow) == 0 {
+		fmt.Println("Error: No commands are allowed, add them to host_exec.allow in the config.")
+		return
+	}
+
+	stop, err := startHostExecBridge(cfg, os.Stdout)
+	if err != nil {
+		fmt.Printf("Error starting host-exec bridge: %v\n", err)
+		return
+	}
+	defer stop()
+
+	fmt.Printf("Serving host commands for dockerbx containers: %v\n", cfg.HostExec.Allow)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+}
+
+// startHostExecBridge starts serving the host-exec socket in the background
+// and returns a function that stops it.
+func startHostExecBridge(cfg *config.Config, log io.Writer) (stop func(), err error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := hostexec.Listen(hostexec.SocketDir(configDir))
+	if err != nil {
+		return nil, err
+	}
+
+	server := &hostexec.Server{Allow: cfg.HostExec.Allow, Log: log}
+	for _, m := range cfg.Mounts {
+		server.Mounts = append(server.Mounts, hostexec.Mount{
+			Source: os.ExpandEnv(m.Source),
+			Target: m.Target,
+		})
+	}
+
+	go server.Serve(listener)
+
+	return func() { listener.Close() }, nil
+}
+
+// hostExecMount returns the mount that exposes the host-exec socket
+// directory to a new container, creating the directory if needed.
+func hostExecMount() (mount.Mount, error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return mount.Mount{}, err
+	}
+
+	dir := hostexec.SocketDir(configDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return mount.Mount{}, err
+	}
+
+	return mount.Mount{
+		Type:   mount.TypeBind,
+		Source: dir,
+		Target: hostexec.ContainerDir,
+	}, nil
+}
+
+// setupHostExec installs the dockerbx-host-exec helper in a new container.
+func setupHostExec(ctx context.Context, cli *client.Client, containerID string) error {
+	return runSetupScript(ctx, cli, containerID, hostExecSetupScript, []string{
+		"DOCKERBX_HELPER=" + hostexec.Helper,
+		"DOCKERBX_HELPER_PATH=" + hostexec.HelperPath,
+	})
+}
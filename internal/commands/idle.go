@@ -0,0 +1,387 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/albertoperdomo2/dockerbx/internal/config"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// idleCheckInterval is how often the watcher looks for idle containers.
+	idleCheckInterval = 30 * time.Second
+	// idleRetryDelay is how long the watcher waits before reconnecting to
+	// the events stream.
+	idleRetryDelay = 5 * time.Second
+
+	idleLockName = "idle-watcher.lock"
+	idleLogName  = "idle-watcher.log"
+)
+
+// sessionsRunningScript exits 0 if a dockerbx tmux server is running in the
+// container, whoever it belongs to.
+const sessionsRunningScript = `
+for cmdline in /proc/[0-9]*/cmdline; do
+	case "$(tr '\0' ' ' < "$cmdline" 2>/dev/null)" in
+		*tmux*"-L $0"*) exit 0 ;;
+	esac
+done
+exit 1
+`
+
+func IdleWatcherCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "idle-watcher",
+		Short: "Stop dockerbx containers that have been idle for too long",
+		Long: `Watch the sessions opened in dockerbx containers and stop the running
+containers that have had none for the idle_stop duration of their profile.
+Containers with detached dockerbx sessions are left running.
+
+dockerbx enter, run and start launch the watcher in the background when any
+profile sets idle_stop, so it rarely needs to be run by hand.`,
+		Run: runIdleWatcher,
+	}
+
+	cmd.Flags().Bool("exit-when-done", false, "Exit once no running container has an idle_stop policy")
+
+	return cmd
+}
+
+func runIdleWatcher(cmd *cobra.Command, args []string) {
+	exitWhenDone, _ := cmd.Flags().GetBool("exit-when-done")
+
+	unlock, err := lockIdleWatcher()
+	if err != nil {
+		if exitWhenDone {
+			// started in the background while another watcher runs
+			return
+		}
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	defer unlock()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		fmt.Printf("Error creating Docker client: %v\n", err)
+		return
+	}
+
+	watcher := &idleWatcher{cli: cli, log: os.Stdout}
+	watcher.run(ctx, exitWhenDone)
+}
+
+// idleWatcher tracks the execs running in dockerbx containers and since when
+// each container has had none.
+type idleWatcher struct {
+	cli *client.Client
+	log io.Writer
+
+	mu        sync.Mutex
+	execs     map[string]map[string]bool
+	idleSince map[string]time.Time
+}
+
+func (w *idleWatcher) run(ctx context.Context, exitWhenDone bool) {
+	w.execs = map[string]map[string]bool{}
+	w.idleSince = map[string]time.Time{}
+
+	go w.followEvents(ctx)
+
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		watching, err := w.stopIdle(ctx)
+		if err != nil {
+			w.logf("checking containers: %v", err)
+			continue
+		}
+		if !watching && exitWhenDone {
+			w.logf("no running container has an idle_stop policy, exiting")
+			return
+		}
+	}
+}
+
+// followEvents keeps the exec counts up to date from the Docker events
+// stream, resynchronising whenever the stream has to be reopened.
+func (w *idleWatcher) followEvents(ctx context.Context) {
+	for ctx.Err() == nil {
+		messages, errs := w.cli.Events(ctx, events.ListOptions{
+			Filters: filters.NewArgs(
+				filters.Arg("type", string(events.ContainerEventType)),
+				filters.Arg("label", "owned_by=dockerbx"),
+			),
+		})
+
+		if err := w.sync(ctx); err != nil {
+			w.logf("reading running execs: %v", err)
+		}
+
+	stream:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errs:
+				if ctx.Err() == nil {
+					w.logf("events stream closed: %v", err)
+				}
+				break stream
+			case message := <-messages:
+				w.handleEvent(message)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(idleRetryDelay):
+		}
+	}
+}
+
+// sync rebuilds the exec counts from the running containers, for the execs
+// started before the events stream was opened.
+func (w *idleWatcher) sync(ctx context.Context) error {
+	containers, err := ListDockerBxContainers(ctx, w.cli, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("status", "running")),
+	})
+	if err != nil {
+		return err
+	}
+
+	execs := map[string]map[string]bool{}
+	for _, c := range containers {
+		containerJSON, err := w.cli.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			continue
+		}
+		execs[c.ID] = map[string]bool{}
+		for _, execID := range containerJSON.ExecIDs {
+			if execRunning(ctx, w.cli, execID) {
+				execs[c.ID][execID] = true
+			}
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.execs = execs
+	now := time.Now()
+	for id, running := range execs {
+		if _, ok := w.idleSince[id]; !ok || len(running) > 0 {
+			w.idleSince[id] = now
+		}
+	}
+	return nil
+}
+
+func (w *idleWatcher) handleEvent(message events.Message) {
+	id := message.Actor.ID
+	action := string(message.Action)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(action, string(events.ActionExecStart)):
+		if w.execs[id] == nil {
+			w.execs[id] = map[string]bool{}
+		}
+		w.execs[id][message.Actor.Attributes["execID"]] = true
+	case action == string(events.ActionExecDie):
+		delete(w.execs[id], message.Actor.Attributes["execID"])
+		if len(w.execs[id]) == 0 {
+			w.idleSince[id] = time.Now()
+		}
+	case action == string(events.ActionStart):
+		w.execs[id] = map[string]bool{}
+		w.idleSince[id] = time.Now()
+	case action == string(events.ActionDie):
+		delete(w.execs, id)
+		delete(w.idleSince, id)
+	}
+}
+
+// stopIdle stops the running containers that have been idle for longer than
+// their profile allows. It reports whether any running container has an
+// idle policy.
+func (w *idleWatcher) stopIdle(ctx context.Context) (bool, error) {
+	// reload the config so that changes apply without a restart
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return false, err
+	}
+
+	containers, err := ListDockerBxContainers(ctx, w.cli, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("status", "running")),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	watching := false
+	now := time.Now()
+	for _, c := range containers {
+		name := containerName(c)
+		profile := cfg.Profile(c.Labels["profile"])
+		if profile.IdleStop == "" {
+			continue
+		}
+		idleStop, err := time.ParseDuration(profile.IdleStop)
+		if err != nil {
+			w.logf("%s: invalid idle_stop %q: %v", name, profile.IdleStop, err)
+			continue
+		}
+		if idleStop <= 0 {
+			continue
+		}
+		watching = true
+
+		w.mu.Lock()
+		active := len(w.execs[c.ID]) > 0
+		since, ok := w.idleSince[c.ID]
+		if !ok {
+			since = now
+			w.idleSince[c.ID] = now
+		}
+		w.mu.Unlock()
+
+		if active || now.Sub(since) < idleStop {
+			continue
+		}
+
+		if sessionsRunning(ctx, w.cli, c.ID) {
+			w.mu.Lock()
+			w.idleSince[c.ID] = now
+			w.mu.Unlock()
+			continue
+		}
+
+		opts, err := profileStopOptions(profile)
+		if err != nil {
+			w.logf("%s: %v", name, err)
+			continue
+		}
+		if err := w.cli.ContainerStop(ctx, c.ID, opts); err != nil {
+			w.logf("%s: stopping: %v", name, err)
+			continue
+		}
+		w.logf("stopped %s after %s without sessions", name, now.Sub(since).Round(time.Second))
+	}
+
+	return watching, nil
+}
+
+func (w *idleWatcher) logf(format string, args ...interface{}) {
+	fmt.Fprintf(w.log, "%s idle-watcher: %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+// sessionsRunning reports whether the container hosts detached dockerbx
+// sessions, which would be lost if it were stopped.
+func sessionsRunning(ctx context.Context, cli *client.Client, containerID string) bool {
+	exitCode, _, err := execInContainer(ctx, cli, containerID, types.ExecConfig{
+		User: "root",
+		Cmd:  []string{"/bin/sh", "-c", sessionsRunningScript, sessionSocket},
+	})
+	return err == nil && exitCode == 0
+}
+
+// lockIdleWatcher makes sure only one watcher runs at a time, returning a
+// function that releases the lock.
+func lockIdleWatcher() (func(), error) {
+	configDir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return nil, err
+	}
+
+	lockFile, err := os.OpenFile(filepath.Join(configDir, idleLockName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		lockFile.Close()
+		return nil, fmt.Errorf("idle watcher already running")
+	}
+
+	return func() { lockFile.Close() }, nil
+}
+
+// ensureIdleWatcher starts the idle watcher in the background if any profile
+// sets idle_stop. A watcher that is already running keeps the lock, so the
+// new one exits straight away.
+func ensureIdleWatcher(cfg *config.Config) {
+	if cfg == nil || !hasIdlePolicy(cfg) {
+		return
+	}
+
+	unlock, err := lockIdleWatcher()
+	if err != nil {
+		return
+	}
+	unlock()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return
+	}
+	configDir, err := config.Dir()
+	if err != nil {
+		return
+	}
+	logFile, err := os.OpenFile(filepath.Join(configDir, idleLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer logFile.Close()
+
+	watcher := exec.Command(executable, "idle-watcher", "--exit-when-done")
+	watcher.Stdout = logFile
+	watcher.Stderr = logFile
+	watcher.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := watcher.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not start idle watcher: %v\n", err)
+		return
+	}
+	watcher.Process.Release()
+}
+
+func hasIdlePolicy(cfg *config.Config) bool {
+	if cfg.Defaults.IdleStop != "" {
+		return true
+	}
+	for _, profile := range cfg.Profiles {
+		if profile.IdleStop != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,287 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/albertoperdomo2/dockerbx/internal/config"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+func InspectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect [container_name]",
+		Short: "Show how a dockerbx container was created and set up",
+		Long: `Show a dockerbx container's image, state, labels, profile, the flags it was
+created with, its mounts and forwarded ports, the outcome of each provisioning
+step and the binaries exported from it.`,
+		Run: runInspect,
+	}
+
+	cmd.Flags().String("format", "text", "Output format: text or json")
+
+	return cmd
+}
+
+// inspectInfo is what inspect reports about a container.
+type inspectInfo struct {
+	Name         string            `json:"name"`
+	ID           string            `json:"id"`
+	Image        string            `json:"image"`
+	Type         string            `json:"type,omitempty"`
+	Profile      string            `json:"profile,omitempty"`
+	Shell        string            `json:"shell,omitempty"`
+	Created      time.Time         `json:"created"`
+	State        string            `json:"state"`
+	StartedAt    string            `json:"started_at,omitempty"`
+	FinishedAt   string            `json:"finished_at,omitempty"`
+	CreateFlags  map[string]string `json:"create_flags"`
+	Labels       map[string]string `json:"labels"`
+	Mounts       []inspectMount    `json:"mounts"`
+	Ports        []string          `json:"ports"`
+	Provisioning *provisionRecord  `json:"provisioning"`
+	Exports      []config.Export   `json:"exports"`
+}
+
+type inspectMount struct {
+	Type        string `json:"type"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	ReadWrite   bool   `json:"read_write"`
+}
+
+func runInspect(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		fmt.Printf("Error creating Docker client: %v\n", err)
+		return
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		fmt.Printf("Error: Unknown format %q, use text or json.\n", format)
+		return
+	}
+
+	containerName := ""
+	if len(args) > 0 {
+		containerName = args[0]
+	} else {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			return
+		}
+		containerName = cfg.DefaultName
+	}
+
+	info, err := inspectContainer(ctx, cli, containerName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if format == "json" {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			fmt.Printf("Error marshaling container: %v\n", err)
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	printInspectInfo(info)
+}
+
+// inspectContainer gathers the Docker state of a dockerbx container together
+// with the metadata dockerbx keeps about it.
+func inspectContainer(ctx context.Context, cli *client.Client, containerName string) (*inspectInfo, error) {
+	containerJSON, err := cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return nil, fmt.Errorf("container '%s' not found", containerName)
+	}
+	labels := containerJSON.Config.Labels
+	if labels["owned_by"] != "dockerbx" {
+		return nil, fmt.Errorf("container '%s' is not a dockerbx container", containerName)
+	}
+
+	info := &inspectInfo{
+		Name:        strings.TrimPrefix(containerJSON.Name, "/"),
+		ID:          containerJSON.ID,
+		Image:       containerJSON.Config.Image,
+		Type:        labels["type"],
+		Profile:     labels["profile"],
+		Shell:       labels["shell"],
+		State:       containerJSON.State.Status,
+		CreateFlags: map[string]string{},
+		Labels:      labels,
+		Mounts:      []inspectMount{},
+		Ports:       []string{},
+		Exports:     []config.Export{},
+	}
+	info.Created, _ = time.Parse(time.RFC3339Nano, containerJSON.Created)
+	if formatInspectTime(containerJSON.State.StartedAt) != "" {
+		info.StartedAt = containerJSON.State.StartedAt
+	}
+	if !containerJSON.State.Running && formatInspectTime(containerJSON.State.FinishedAt) != "" {
+		info.FinishedAt = containerJSON.State.FinishedAt
+	}
+
+	if flags := labels[createFlagsLabel]; flags != "" {
+		if err := json.Unmarshal([]byte(flags), &info.CreateFlags); err != nil {
+			return nil, fmt.Errorf("reading %s label: %w", createFlagsLabel, err)
+		}
+	}
+
+	for _, m := range containerJSON.Mounts {
+		source := m.Source
+		if m.Name != "" {
+			source = m.Name
+		}
+		info.Mounts = append(info.Mounts, inspectMount{
+			Type:        string(m.Type),
+			Source:      source,
+			Destination: m.Destination,
+			ReadWrite:   m.RW,
+		})
+	}
+
+	// the published ports are only known while the container runs, the
+	// requested ones are always in the host config
+	bindings := containerJSON.HostConfig.PortBindings
+	if containerJSON.NetworkSettings != nil && len(containerJSON.NetworkSettings.Ports) > 0 {
+		bindings = containerJSON.NetworkSettings.Ports
+	}
+	for port, hostBindings := range bindings {
+		if len(hostBindings) == 0 {
+			info.Ports = append(info.Ports, string(port))
+		}
+		for _, binding := range hostBindings {
+			info.Ports = append(info.Ports, fmt.Sprintf("%s -> %s:%s", port, binding.HostIP, binding.HostPort))
+		}
+	}
+	sort.Strings(info.Ports)
+
+	info.Provisioning, err = loadProvisioning(ctx, cli, containerJSON.ID)
+	if err != nil {
+		return nil, fmt.Errorf("reading provisioning status: %w", err)
+	}
+
+	exports, err := config.LoadExports()
+	if err != nil {
+		return nil, fmt.Errorf("loading exported binaries: %w", err)
+	}
+	for _, export := range exports {
+		if export.Container == info.Name {
+			info.Exports = append(info.Exports, export)
+		}
+	}
+
+	return info, nil
+}
+
+func printInspectInfo(info *inspectInfo) {
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Printf("%-14s%s\n", name+":", value)
+		}
+	}
+
+	field("Name", info.Name)
+	field("ID", info.ID)
+	field("Image", info.Image)
+	field("Type", info.Type)
+	field("Profile", info.Profile)
+	if info.Shell != "" {
+		field("Shell", info.Shell)
+	} else {
+		field("Shell", "detected on enter")
+	}
+	if !info.Created.IsZero() {
+		field("Created", fmt.Sprintf("%s (%s ago)", info.Created.Local().Format(time.RFC1123), units.HumanDuration(time.Since(info.Created))))
+	}
+	field("State", info.State)
+	field("Started", formatInspectTime(info.StartedAt))
+	field("Finished", formatInspectTime(info.FinishedAt))
+
+	if len(info.CreateFlags) > 0 {
+		var flags []string
+		for name, value := range info.CreateFlags {
+			flags = append(flags, fmt.Sprintf("--%s=%s", name, value))
+		}
+		sort.Strings(flags)
+		field("Create flags", strings.Join(flags, " "))
+	}
+
+	fmt.Println("\nLabels:")
+	var keys []string
+	for key := range info.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("  %s=%s\n", key, info.Labels[key])
+	}
+
+	fmt.Println("\nMounts:")
+	if len(info.Mounts) == 0 {
+		fmt.Println("  none")
+	}
+	for _, m := range info.Mounts {
+		mode := "ro"
+		if m.ReadWrite {
+			mode = "rw"
+		}
+		fmt.Printf("  %s -> %s (%s, %s)\n", m.Source, m.Destination, m.Type, mode)
+	}
+
+	fmt.Println("\nPorts:")
+	if len(info.Ports) == 0 {
+		fmt.Println("  none")
+	}
+	for _, port := range info.Ports {
+		fmt.Printf("  %s\n", port)
+	}
+
+	fmt.Println("\nProvisioning:")
+	switch {
+	case info.Provisioning == nil:
+		fmt.Println("  unknown, the container predates provisioning records")
+	case len(info.Provisioning.Steps) == 0:
+		fmt.Println("  no setup steps were run")
+	default:
+		for _, step := range info.Provisioning.Steps {
+			status := step.Status
+			if step.Error != "" {
+				status += ": " + step.Error
+			}
+			fmt.Printf("  %-18s%s\n", step.Name, status)
+		}
+	}
+
+	fmt.Println("\nExported binaries:")
+	if len(info.Exports) == 0 {
+		fmt.Println("  none")
+	}
+	for _, export := range info.Exports {
+		fmt.Printf("  %s -> %s\n", export.Binary, export.Shim)
+	}
+}
+
+// formatInspectTime shows a Docker timestamp in local time, or nothing if it
+// is unset.
+func formatInspectTime(value string) string {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil || t.Year() <= 1 {
+		return ""
+	}
+	return t.Local().Format(time.RFC1123)
+}
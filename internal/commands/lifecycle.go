@@ -0,0 +1,206 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/albertoperdomo2/dockerbx/internal/config"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+)
+
+func StartCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "start [container_name...]",
+		Short: "Start stopped dockerbx containers",
+		Run: func(cmd *cobra.Command, args []string) {
+			runLifecycle(cmd, args, "start")
+		},
+	}
+
+	cmd.Flags().BoolP("all", "a", false, "Start all dockerbx containers")
+
+	return cmd
+}
+
+func StopCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stop [container_name...]",
+		Short: "Stop running dockerbx containers",
+		Long: `Stop running dockerbx containers. The stop signal is sent first, and the
+container is killed if it has not exited when the timeout expires. Both default
+to the stop_signal and stop_timeout settings of the container's profile.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runLifecycle(cmd, args, "stop")
+		},
+	}
+
+	cmd.Flags().BoolP("all", "a", false, "Stop all dockerbx containers")
+	addStopFlags(cmd)
+
+	return cmd
+}
+
+func RestartCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restart [container_name...]",
+		Short: "Restart dockerbx containers",
+		Run: func(cmd *cobra.Command, args []string) {
+			runLifecycle(cmd, args, "restart")
+		},
+	}
+
+	cmd.Flags().BoolP("all", "a", false, "Restart all dockerbx containers")
+	addStopFlags(cmd)
+
+	return cmd
+}
+
+func addStopFlags(cmd *cobra.Command) {
+	cmd.Flags().IntP("time", "t", 0, "Seconds to wait before killing the container, default is the profile's stop_timeout or 10")
+	cmd.Flags().StringP("signal", "s", "", "Signal to send first, default is the profile's stop_signal or the image's")
+}
+
+// runLifecycle starts, stops or restarts every target, carrying on past
+// failures and exiting non-zero if any container failed.
+func runLifecycle(cmd *cobra.Command, args []string, action string) {
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		fmt.Printf("Error creating Docker client: %v\n", err)
+		return
+	}
+
+	all, _ := cmd.Flags().GetBool("all")
+
+	// the config is optional, it only provides the default container and
+	// the profile stop settings
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		cfg = &config.Config{}
+	}
+
+	var containerNames []string
+	switch {
+	case all:
+		containers, err := ListDockerBxContainers(ctx, cli, container.ListOptions{})
+		if err != nil {
+			fmt.Printf("Error listing containers: %v\n", err)
+			return
+		}
+		for _, c := range containers {
+			containerNames = append(containerNames, containerName(c))
+		}
+	case len(args) > 0:
+		containerNames = args
+	case cfg.DefaultName != "":
+		containerNames = []string{cfg.DefaultName}
+	default:
+		fmt.Println("Error: Please provide a container name or use --all.")
+		return
+	}
+
+	if action != "stop" {
+		ensureIdleWatcher(cfg)
+	}
+
+	failed := 0
+	for _, name := range containerNames {
+		if err := lifecycleAction(ctx, cli, cmd, cfg, name, action); err != nil {
+			fmt.Printf("Error: %s: %v\n", name, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("Failed to %s %d of %d containers.\n", action, failed, len(containerNames))
+		os.Exit(1)
+	}
+}
+
+func lifecycleAction(ctx context.Context, cli *client.Client, cmd *cobra.Command, cfg *config.Config, name string, action string) error {
+	containerJSON, err := cli.ContainerInspect(ctx, name)
+	if err != nil {
+		return fmt.Errorf("container not found")
+	}
+	if containerJSON.Config.Labels["owned_by"] != "dockerbx" {
+		return fmt.Errorf("not a dockerbx container")
+	}
+	name = strings.TrimPrefix(containerJSON.Name, "/")
+
+	switch action {
+	case "start":
+		if containerJSON.State.Running {
+			fmt.Printf("Container %s is already running\n", name)
+			return nil
+		}
+		if err := cli.ContainerStart(ctx, containerJSON.ID, container.StartOptions{}); err != nil {
+			return err
+		}
+		fmt.Printf("Container %s started\n", name)
+	case "stop":
+		if !containerJSON.State.Running {
+			fmt.Printf("Container %s is not running\n", name)
+			return nil
+		}
+		opts, err := stopOptions(cmd, cfg, containerJSON)
+		if err != nil {
+			return err
+		}
+		if err := cli.ContainerStop(ctx, containerJSON.ID, opts); err != nil {
+			return err
+		}
+		fmt.Printf("Container %s stopped\n", name)
+	case "restart":
+		opts, err := stopOptions(cmd, cfg, containerJSON)
+		if err != nil {
+			return err
+		}
+		if err := cli.ContainerRestart(ctx, containerJSON.ID, opts); err != nil {
+			return err
+		}
+		fmt.Printf("Container %s restarted\n", name)
+	}
+
+	return nil
+}
+
+// stopOptions combines the --time and --signal flags with the stop settings
+// of the container's profile, the flags taking precedence.
+func stopOptions(cmd *cobra.Command, cfg *config.Config, containerJSON types.ContainerJSON) (container.StopOptions, error) {
+	opts, err := profileStopOptions(cfg.Profile(containerJSON.Config.Labels["profile"]))
+	if err != nil {
+		return opts, err
+	}
+
+	if cmd.Flags().Changed("time") {
+		seconds, _ := cmd.Flags().GetInt("time")
+		opts.Timeout = &seconds
+	}
+	if cmd.Flags().Changed("signal") {
+		opts.Signal, _ = cmd.Flags().GetString("signal")
+	}
+
+	return opts, nil
+}
+
+// profileStopOptions returns the stop signal and timeout of a profile.
+func profileStopOptions(profile config.Profile) (container.StopOptions, error) {
+	opts := container.StopOptions{Signal: profile.StopSignal}
+
+	if profile.StopTimeout != "" {
+		timeout, err := time.ParseDuration(profile.StopTimeout)
+		if err != nil {
+			return opts, fmt.Errorf("invalid stop_timeout %q: %w", profile.StopTimeout, err)
+		}
+		seconds := int(timeout.Seconds())
+		opts.Timeout = &seconds
+	}
+
+	return opts, nil
+}
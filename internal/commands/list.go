@@ -2,21 +2,115 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"text/template"
+	"text/template/parse"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 )
 
 func ListCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List dockerbx containers",
-		Run:   runList,
+		Long: `List dockerbx containers.
+
+--format accepts table (the default), json, yaml or a Go template such as
+'{{.Name}} {{.State}}'. Prefix a template with "table " to get aligned columns
+with headers. The fields are ID, Name, Image, Command, Created, State, Status,
+Profile, Type, Ports, Mounts, Size and Labels:
+
+  dockerbx list --format 'table {{.Name}}\t{{join .Ports ", "}}\t{{join .Mounts ", "}}'
+
+--filter narrows the list and may be repeated, every filter must match:
+
+  state=running     created, running, paused, restarting, exited or dead
+  type=python       containers created by dockerbx python
+  image=fedora:40   containers created from the image or its descendants
+  profile=work      containers created with the profile
+  name=web-*        names matching the glob
+  label=key[=value] containers carrying the label
+
+--sort orders the list by name, created (newest first) or last-used (most
+recently started or stopped first, running containers before the rest).`,
+		Run: runList,
 	}
+
+	cmd.Flags().String("format", "table", "Output format: table, json, yaml or a Go template")
+	cmd.Flags().Bool("no-trunc", false, "Don't truncate output")
+	cmd.Flags().BoolP("quiet", "q", false, "Only print container IDs")
+	cmd.Flags().BoolP("size", "s", false, "Include the size of each container's writable layer")
+	cmd.Flags().StringArrayP("filter", "f", nil, "Filter output, e.g. state=running, type=python, image=, profile=, name=glob, label=key[=value]")
+	cmd.Flags().String("sort", "", "Sort by name, created or last-used")
+
+	return cmd
+}
+
+// containerSummary is the view of a dockerbx container printed by list.
+type containerSummary struct {
+	ID      string            `json:"id" yaml:"id"`
+	Name    string            `json:"name" yaml:"name"`
+	Image   string            `json:"image" yaml:"image"`
+	Command string            `json:"command" yaml:"command"`
+	Created time.Time         `json:"created" yaml:"created"`
+	State   string            `json:"state" yaml:"state"`
+	Status  string            `json:"status" yaml:"status"`
+	Profile string            `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Type    string            `json:"type,omitempty" yaml:"type,omitempty"`
+	Ports   []string          `json:"ports" yaml:"ports"`
+	Mounts  []string          `json:"mounts" yaml:"mounts"`
+	Size    int64             `json:"size,omitempty" yaml:"size,omitempty"`
+	Labels  map[string]string `json:"labels" yaml:"labels"`
+}
+
+func newContainerSummary(c types.Container) containerSummary {
+	summary := containerSummary{
+		ID:      c.ID,
+		Name:    containerName(c),
+		Image:   c.Image,
+		Command: c.Command,
+		Created: time.Unix(c.Created, 0),
+		State:   c.State,
+		Status:  c.Status,
+		Profile: c.Labels["profile"],
+		Type:    c.Labels["type"],
+		Ports:   []string{},
+		Mounts:  []string{},
+		Size:    c.SizeRw,
+		Labels:  c.Labels,
+	}
+
+	for _, p := range c.Ports {
+		if p.PublicPort != 0 {
+			summary.Ports = append(summary.Ports, fmt.Sprintf("%s:%d->%d/%s", p.IP, p.PublicPort, p.PrivatePort, p.Type))
+		} else {
+			summary.Ports = append(summary.Ports, fmt.Sprintf("%d/%s", p.PrivatePort, p.Type))
+		}
+	}
+	sort.Strings(summary.Ports)
+
+	for _, m := range c.Mounts {
+		source := m.Source
+		if m.Name != "" {
+			source = m.Name
+		}
+		summary.Mounts = append(summary.Mounts, source+":"+m.Destination)
+	}
+
+	return summary
 }
 
 func runList(cmd *cobra.Command, args []string) {
@@ -27,68 +121,371 @@ func runList(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+	format, _ := cmd.Flags().GetString("format")
+	noTrunc, _ := cmd.Flags().GetBool("no-trunc")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	withSize, _ := cmd.Flags().GetBool("size")
+	filterValues, _ := cmd.Flags().GetStringArray("filter")
+	sortBy, _ := cmd.Flags().GetString("sort")
+
+	listFilters, err := parseListFilters(filterValues)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	if sortBy != "" && sortBy != "name" && sortBy != "created" && sortBy != "last-used" {
+		fmt.Printf("Error: Unknown sort key %q, use name, created or last-used.\n", sortBy)
+		return
+	}
+
+	containers, err := ListDockerBxContainers(ctx, cli, container.ListOptions{Size: withSize, Filters: listFilters})
 	if err != nil {
 		fmt.Printf("Error listing containers: %v\n", err)
 		return
 	}
 
-	var dockerbxContainers []types.Container
+	if err := sortContainers(ctx, cli, containers, sortBy); err != nil {
+		fmt.Printf("Error sorting containers: %v\n", err)
+		return
+	}
+
+	var dockerbxContainers []containerSummary
 	for _, container := range containers {
-		if value, exists := container.Labels["owned_by"]; exists {
-			if value == "dockerbx" {
-				dockerbxContainers = append(dockerbxContainers, container)
+		dockerbxContainers = append(dockerbxContainers, newContainerSummary(container))
+	}
+
+	if quiet {
+		for _, c := range dockerbxContainers {
+			if noTrunc {
+				fmt.Println(c.ID)
+			} else {
+				fmt.Println(shortID(c.ID))
 			}
 		}
+		return
 	}
 
-	if len(dockerbxContainers) > 0 {
-		idWidth, nameWidth, commandWidth, stateWidth := 20, 20, 30, 10
-		format := fmt.Sprintf("%%-%ds%%-%ds%%-%ds%%-%ds\n", idWidth, nameWidth, commandWidth, stateWidth)
-		fmt.Printf(format, "CONTAINER_ID", "NAME", "COMMAND", "STATE")
+	switch {
+	case format == "json":
+		data, err := json.MarshalIndent(nonNil(dockerbxContainers), "", "  ")
+		if err != nil {
+			fmt.Printf("Error marshaling containers: %v\n", err)
+			return
+		}
+		fmt.Println(string(data))
+	case format == "yaml":
+		data, err := yaml.Marshal(nonNil(dockerbxContainers))
+		if err != nil {
+			fmt.Printf("Error marshaling containers: %v\n", err)
+			return
+		}
+		fmt.Print(string(data))
+	case format == "table" || format == "":
+		if len(dockerbxContainers) == 0 {
+			fmt.Printf("No containers owned by \"dockerbx\" found.\n")
+			return
+		}
+		printContainerTable(dockerbxContainers, noTrunc, withSize)
+	default:
+		if err := printContainerTemplate(dockerbxContainers, format); err != nil {
+			fmt.Printf("Error formatting output: %v\n", err)
+		}
+	}
+}
+
+// printContainerTable prints the default fixed-width table. With noTrunc
+// the columns grow to fit their contents instead of truncating them.
+func printContainerTable(containers []containerSummary, noTrunc bool, withSize bool) {
+	headers := []string{"CONTAINER_ID", "NAME", "IMAGE", "COMMAND", "CREATED", "STATE", "PROFILE", "PORTS", "MOUNTS"}
+	widths := []int{15, 20, 20, 25, 16, 10, 12, 25, 30}
+	if withSize {
+		headers = append(headers, "SIZE")
+		widths = append(widths, 10)
+	}
 
-		for _, dockerbxContainer := range dockerbxContainers {
-			name := ""
-			if len(dockerbxContainer.Names) > 0 {
-				name = strings.TrimPrefix(dockerbxContainer.Names[0], "/")
+	rows := make([][]string, 0, len(containers))
+	for _, c := range containers {
+		id := shortID(c.ID)
+		if noTrunc {
+			id = c.ID
+		}
+		profile := c.Profile
+		if profile == "" {
+			profile = c.Type
+		}
+		row := []string{
+			id,
+			c.Name,
+			c.Image,
+			c.Command,
+			units.HumanDuration(time.Since(c.Created)) + " ago",
+			c.State,
+			profile,
+			strings.Join(c.Ports, ", "),
+			strings.Join(c.Mounts, ", "),
+		}
+		if withSize {
+			row = append(row, units.HumanSizeWithPrecision(float64(c.Size), 3))
+		}
+		rows = append(rows, row)
+	}
+
+	if noTrunc {
+		for i, header := range headers {
+			widths[i] = len(header) + 2
+			for _, row := range rows {
+				if len(row[i])+2 > widths[i] {
+					widths[i] = len(row[i]) + 2
+				}
 			}
+		}
+	}
 
-			id := truncateString(dockerbxContainer.ID, idWidth)
-			name = truncateString(name, nameWidth)
-			command := truncateString(dockerbxContainer.Command, commandWidth)
-			state := truncateString(dockerbxContainer.State, stateWidth)
+	var format strings.Builder
+	for _, width := range widths {
+		fmt.Fprintf(&format, "%%-%ds", width)
+	}
+	format.WriteString("\n")
 
-			fmt.Printf(format, id, name, command, state)
+	printRow := func(values []string) {
+		cells := make([]interface{}, len(values))
+		for i, value := range values {
+			if !noTrunc {
+				value = truncateString(value, widths[i])
+			}
+			cells[i] = value
 		}
-	} else {
-		fmt.Printf("No containers owned by \"dockerbx\" found.\n")
-		return
+		fmt.Printf(format.String(), cells...)
 	}
-}
 
-func truncateString(s string, maxLength int) string {
-	if len(s) <= maxLength {
-		return s
+	printRow(headers)
+	for _, row := range rows {
+		printRow(row)
 	}
-	return s[:maxLength-5] + "...  "
 }
 
-// List containers owned by dockerbx
-func ListDockerBxContainers(ctx context.Context, cli *client.Client) ([]types.Container, error) {
-	var dockerbxContainers []types.Container
+// listTemplateFuncs are the functions available to --format templates.
+var listTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
 
-	containers, err := cli.ContainerList(ctx, container.ListOptions{All: true})
+// printContainerTemplate renders every container with a Go template, as
+// docker ps --format does. A "table " prefix aligns the output in columns
+// under a header row.
+func printContainerTemplate(containers []containerSummary, format string) error {
+	table := strings.HasPrefix(format, "table ")
+	format = strings.TrimPrefix(format, "table ")
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+
+	tmpl, err := template.New("format").Funcs(listTemplateFuncs).Parse(format)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	for _, container := range containers {
-		if value, exists := container.Labels["owned_by"]; exists {
-			if value == "dockerbx" {
-				dockerbxContainers = append(dockerbxContainers, container)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 3, ' ', 0)
+	if table {
+		fmt.Fprintln(w, templateHeader(tmpl.Tree.Root))
+	}
+
+	for _, c := range containers {
+		if err := tmpl.Execute(w, c); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	return w.Flush()
+}
+
+// templateHeader renders the header row of a table template: its text as
+// is, and each action as the upper-cased name of the first field it uses.
+func templateHeader(root *parse.ListNode) string {
+	var header strings.Builder
+	for _, node := range root.Nodes {
+		if text, ok := node.(*parse.TextNode); ok {
+			header.Write(text.Text)
+			continue
+		}
+		header.WriteString(strings.ToUpper(firstTemplateField(node)))
+	}
+	return header.String()
+}
+
+// firstTemplateField returns the first field referenced under node, such as
+// "Ports" for {{join .Ports ", "}}, or "" if there is none.
+func firstTemplateField(node parse.Node) string {
+	var children []parse.Node
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		return strings.Join(n.Ident, ".")
+	case *parse.ChainNode:
+		if len(n.Field) > 0 {
+			return strings.Join(n.Field, ".")
+		}
+		children = []parse.Node{n.Node}
+	case *parse.ActionNode:
+		children = []parse.Node{n.Pipe}
+	case *parse.PipeNode:
+		if n == nil {
+			return ""
+		}
+		for _, cmd := range n.Cmds {
+			children = append(children, cmd)
+		}
+	case *parse.CommandNode:
+		children = n.Args
+	case *parse.ListNode:
+		if n == nil {
+			return ""
+		}
+		children = n.Nodes
+	case *parse.IfNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.RangeNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	case *parse.WithNode:
+		children = []parse.Node{n.Pipe, n.List, n.ElseList}
+	}
+
+	for _, child := range children {
+		if field := firstTemplateField(child); field != "" {
+			return field
+		}
+	}
+	return ""
+}
+
+// parseListFilters turns the key=value --filter flags into Docker list
+// filters, so that the daemon does the filtering.
+func parseListFilters(values []string) (filters.Args, error) {
+	args := filters.NewArgs()
+	for _, value := range values {
+		key, arg, ok := strings.Cut(value, "=")
+		if !ok || arg == "" {
+			return args, fmt.Errorf("invalid filter %q, expected key=value", value)
+		}
+
+		switch key {
+		case "state", "status":
+			args.Add("status", arg)
+		case "type":
+			args.Add("label", "type="+arg)
+		case "image", "ancestor":
+			args.Add("ancestor", arg)
+		case "profile":
+			args.Add("label", "profile="+arg)
+		case "name":
+			args.Add("name", globToRegexp(arg))
+		case "label":
+			args.Add("label", arg)
+		default:
+			return args, fmt.Errorf("unknown filter %q, use state, type, image, profile, name or label", key)
+		}
+	}
+	return args, nil
+}
+
+// globToRegexp converts a shell glob to the anchored regular expression the
+// Docker name filter expects. Container names carry a leading slash.
+func globToRegexp(glob string) string {
+	pattern := regexp.QuoteMeta(glob)
+	pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+	pattern = strings.ReplaceAll(pattern, `\?`, ".")
+	return "^/?" + pattern + "$"
+}
+
+// sortContainers orders the containers in place. Sorting by last use needs
+// the start and finish times, which only ContainerInspect reports.
+func sortContainers(ctx context.Context, cli *client.Client, containers []types.Container, sortBy string) error {
+	switch sortBy {
+	case "name":
+		sort.SliceStable(containers, func(i, j int) bool {
+			return containerName(containers[i]) < containerName(containers[j])
+		})
+	case "created":
+		sort.SliceStable(containers, func(i, j int) bool {
+			return containers[i].Created > containers[j].Created
+		})
+	case "last-used":
+		lastUsed := map[string]time.Time{}
+		for _, c := range containers {
+			containerJSON, err := cli.ContainerInspect(ctx, c.ID)
+			if err != nil {
+				return err
 			}
+			lastUsed[c.ID] = containerLastUsed(containerJSON.State)
+		}
+		sort.SliceStable(containers, func(i, j int) bool {
+			return lastUsed[containers[i].ID].After(lastUsed[containers[j].ID])
+		})
+	}
+	return nil
+}
+
+// containerLastUsed returns when the container was last started or stopped,
+// treating a running container as in use now.
+func containerLastUsed(state *types.ContainerState) time.Time {
+	if state == nil {
+		return time.Time{}
+	}
+	if state.Running {
+		return time.Now()
+	}
+
+	var lastUsed time.Time
+	for _, value := range []string{state.StartedAt, state.FinishedAt} {
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil && t.After(lastUsed) {
+			lastUsed = t
 		}
 	}
+	return lastUsed
+}
+
+func containerName(c types.Container) string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// shortID returns the 12 character form of a container ID.
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// nonNil makes empty results marshal as an empty list rather than null.
+func nonNil(containers []containerSummary) []containerSummary {
+	if containers == nil {
+		return []containerSummary{}
+	}
+	return containers
+}
+
+func truncateString(s string, maxLength int) string {
+	if len(s) <= maxLength {
+		return s
+	}
+	return s[:maxLength-5] + "...  "
+}
+
+// List containers owned by dockerbx, stopped ones included, narrowed by any
+// filters in opts
+func ListDockerBxContainers(ctx context.Context, cli *client.Client, opts container.ListOptions) ([]types.Container, error) {
+	opts.All = true
+	if opts.Filters.Len() == 0 {
+		opts.Filters = filters.NewArgs()
+	}
+	opts.Filters.Add("label", "owned_by=dockerbx")
 
-	return dockerbxContainers, nil
+	return cli.ContainerList(ctx, opts)
 }
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"testing"
+	"text/template"
+)
+
+func TestTemplateHeader(t *testing.T) {
+	tests := []struct {
+		format string
+		header string
+	}{
+		{"{{.Name}}\t{{.State}}", "NAME\tSTATE"},
+		{"{{.Name}}\t{{join .Ports \", \"}}\t{{join .Mounts \", \"}}", "NAME\tPORTS\tMOUNTS"},
+		{"{{.ID}} ({{json .Labels}})", "ID (LABELS)"},
+		{"{{if .Profile}}{{.Profile}}{{else}}{{.Type}}{{end}}", "PROFILE"},
+		{"{{.Labels.owned_by}}", "LABELS.OWNED_BY"},
+		{"{{upper \"x\"}}:{{.Size}}", ":SIZE"},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := template.New("format").Funcs(listTemplateFuncs).Parse(tt.format)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.format, err)
+		}
+		if got := templateHeader(tmpl.Tree.Root); got != tt.header {
+			t.Errorf("templateHeader(%q) = %q, want %q", tt.format, got, tt.header)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/albertoperdomo2/dockerbx/internal/config"
+	"github.com/docker/docker/client"
+)
+
+// localeVars are the host environment variables that make up the locale.
+var localeVars = []string{
+	"LANG", "LANGUAGE", "LC_ALL", "LC_CTYPE", "LC_NUMERIC", "LC_TIME", "LC_COLLATE",
+	"LC_MONETARY", "LC_MESSAGES", "LC_PAPER", "LC_NAME", "LC_ADDRESS",
+	"LC_TELEPHONE", "LC_MEASUREMENT", "LC_IDENTIFICATION",
+}
+
+// timezoneSetupScript points /etc/localtime at the zone in $TZ, installing
+// tzdata first if the image ships without it.
+var timezoneSetupScript = `
+[ -n "$TZ" ] || exit 0
+if [ ! -e "/usr/share/zoneinfo/$TZ" ]; then` + installPackageScript("tzdata") + `fi
+[ -e "/usr/share/zoneinfo/$TZ" ] || { echo "unknown timezone $TZ"; exit 1; }
+ln -sf "/usr/share/zoneinfo/$TZ" /etc/localtime
+echo "$TZ" > /etc/timezone
+`
+
+// localeSetupScript makes sure the locale in $DOCKERBX_LOCALE is available,
+// installing the language pack or generating it when missing.
+var localeSetupScript = `
+loc="$DOCKERBX_LOCALE"
+case "$loc" in ""|C|C.*|POSIX) exit 0 ;; esac
+want=$(echo "$loc" | tr 'A-Z' 'a-z' | sed 's/-//g')
+has_locale() {
+	locale -a 2>/dev/null | tr 'A-Z' 'a-z' | sed 's/-//g' | grep -qx "$want"
+}
+has_locale && exit 0
+lang="${loc%%_*}"
+name="${loc%%.*}"
+charset="${loc#*.}"
+[ "$charset" = "$loc" ] && charset="UTF-8"
+# Fedora packages each language, Debian generates locales on demand
+pkg=locales
+command -v dnf >/dev/null 2>&1 && pkg="glibc-langpack-$lang"
+` + installPackageScript(`"$pkg"`) + `
+if ! has_locale; then
+	command -v dnf >/dev/null 2>&1 && dnf install -y glibc-all-langpacks
+	command -v localedef >/dev/null 2>&1 && localedef -i "$name" -c -f "$charset" -A /usr/share/locale/locale.alias "$loc"
+fi
+has_locale || { echo "could not install locale $loc"; exit 1; }
+`
+
+// hostTimezone returns the IANA name of the host timezone, taken from $TZ or
+// from the /etc/localtime symlink.
+func hostTimezone() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" && !strings.HasPrefix(tz, "/") {
+		return tz
+	}
+
+	target, err := os.Readlink("/etc/localtime")
+	if err != nil {
+		return ""
+	}
+	if i := strings.Index(target, "zoneinfo/"); i >= 0 {
+		return target[i+len("zoneinfo/"):]
+	}
+	return ""
+}
+
+// hostLocaleEnv returns the host locale variables in KEY=value form. Values
+// that glibc does not understand, such as the bare "UTF-8" macOS sets in
+// LC_CTYPE, are skipped.
+func hostLocaleEnv() []string {
+	var env []string
+	for _, name := range localeVars {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if name != "LANGUAGE" && !strings.Contains(value, "_") && value != "C" && value != "POSIX" && !strings.HasPrefix(value, "C.") {
+			continue
+		}
+		env = append(env, name+"="+value)
+	}
+	return env
+}
+
+// containerTimezone resolves the timezone to use for new containers.
+func containerTimezone(cfg *config.Config) string {
+	switch cfg.Timezone {
+	case "none":
+		return ""
+	case "", "host":
+		return hostTimezone()
+	default:
+		return cfg.Timezone
+	}
+}
+
+// containerLocaleEnv resolves the locale variables to use for new containers.
+func containerLocaleEnv(cfg *config.Config) []string {
+	switch cfg.Locale {
+	case "none":
+		return nil
+	case "", "host":
+		return hostLocaleEnv()
+	default:
+		return []string{"LANG=" + cfg.Locale}
+	}
+}
+
+// timezoneAndLocaleEnv returns the environment for a new container so that
+// it follows the configured timezone and locale.
+func timezoneAndLocaleEnv(cfg *config.Config) []string {
+	var env []string
+	if tz := containerTimezone(cfg); tz != "" {
+		env = append(env, "TZ="+tz)
+	}
+	return append(env, containerLocaleEnv(cfg)...)
+}
+
+// setupTimezoneAndLocale configures /etc/localtime and installs the locale
+// inside a freshly started container.
+func setupTimezoneAndLocale(ctx context.Context, cli *client.Client, containerID string, cfg *config.Config) error {
+	if tz := containerTimezone(cfg); tz != "" {
+		if err := runSetupScript(ctx, cli, containerID, timezoneSetupScript, []string{"TZ=" + tz}); err != nil {
+			return err
+		}
+	}
+
+	if loc := effectiveLocale(containerLocaleEnv(cfg)); loc != "" {
+		if err := runSetupScript(ctx, cli, containerID, localeSetupScript, []string{"DOCKERBX_LOCALE=" + loc}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// effectiveLocale picks the locale that wins among LC_ALL, LC_CTYPE and LANG.
+func effectiveLocale(env []string) string {
+	values := map[string]string{}
+	for _, kv := range env {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			values[name] = value
+		}
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if values[name] != "" {
+			return values[name]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,313 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"golang.org/x/term"
+)
+
+// pickerHeight is the number of matches the picker shows at once.
+const pickerHeight = 10
+
+var errPickerCancelled = errors.New("cancelled")
+
+// resolveContainerName returns the container a command should act on: the
+// one named on the command line, else the default container if it exists,
+// else the one the user picks when stdin is a terminal.
+func resolveContainerName(ctx context.Context, cli *client.Client, args []string, defaultName string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	if defaultName != "" {
+		if _, err := cli.ContainerInspect(ctx, defaultName); err == nil {
+			return defaultName, nil
+		}
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		if defaultName != "" {
+			return "", fmt.Errorf("container '%s' does not exist, please create it first", defaultName)
+		}
+		return "", fmt.Errorf("please provide a container name")
+	}
+
+	names, err := pickContainers(ctx, cli, "Container", false)
+	if err != nil {
+		return "", err
+	}
+	return names[0], nil
+}
+
+// pickContainers lets the user choose among the dockerbx containers.
+func pickContainers(ctx context.Context, cli *client.Client, prompt string, multi bool) ([]string, error) {
+	containers, err := ListDockerBxContainers(ctx, cli, container.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("no dockerbx containers found, create one with dockerbx create")
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containerName(containers[i]) < containerName(containers[j])
+	})
+
+	nameWidth := 0
+	for _, c := range containers {
+		if len(containerName(c)) > nameWidth {
+			nameWidth = len(containerName(c))
+		}
+	}
+
+	items := make([]string, len(containers))
+	for i, c := range containers {
+		items[i] = fmt.Sprintf("%-*s  %-8s  %s", nameWidth, containerName(c), c.State, c.Image)
+	}
+
+	chosen, err := fuzzyPick(prompt, items, multi)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(chosen))
+	for i, index := range chosen {
+		names[i] = containerName(containers[index])
+	}
+	return names, nil
+}
+
+// fuzzyPick shows the items on the terminal, filtered by what the user types,
+// and returns the indices of the chosen ones. With multi, Tab marks several
+// items; Enter otherwise chooses the highlighted one.
+func fuzzyPick(prompt string, items []string, multi bool) ([]int, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(fd, state)
+
+	out := os.Stderr
+	query := ""
+	cursor := 0
+	selected := map[int]bool{}
+	drawn := 0
+	matches := fuzzyFilter(items, query)
+
+	width := 80
+	if w, _, err := term.GetSize(fd); err == nil && w > 0 {
+		width = w
+	}
+
+	hint := "↑/↓ move, Enter choose, Esc cancel"
+	if multi {
+		hint = "↑/↓ move, Tab mark, Enter choose, Esc cancel"
+	}
+
+	draw := func() {
+		// go back to the prompt line and redraw everything below it
+		if drawn > 0 {
+			fmt.Fprintf(out, "\r\033[%dA", drawn)
+		}
+		fmt.Fprint(out, "\r\033[J")
+
+		lines := 0
+		fmt.Fprintf(out, "\033[2m%s\033[0m\r\n", hint)
+		lines++
+
+		start := 0
+		if cursor >= pickerHeight {
+			start = cursor - pickerHeight + 1
+		}
+		for i := start; i < len(matches) && i < start+pickerHeight; i++ {
+			marker := "  "
+			if i == cursor {
+				marker = "> "
+			}
+			mark := ""
+			if multi {
+				mark = "[ ] "
+				if selected[matches[i]] {
+					mark = "[x] "
+				}
+			}
+			// lines must not wrap, or redrawing would lose track of them
+			line := marker + mark + items[matches[i]]
+			if utf8.RuneCountInString(line) >= width {
+				line = string([]rune(line)[:width-1])
+			}
+			if i == cursor {
+				line = "\033[7m" + line + "\033[0m"
+			}
+			fmt.Fprint(out, line+"\r\n")
+			lines++
+		}
+		if len(matches) == 0 {
+			fmt.Fprint(out, "  no matches\r\n")
+			lines++
+		}
+
+		fmt.Fprintf(out, "%s: %s", prompt, query)
+		drawn = lines
+	}
+
+	erase := func() {
+		fmt.Fprintf(out, "\r\033[%dA\033[J", drawn)
+	}
+
+	draw()
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			erase()
+			return nil, err
+		}
+
+		switch r {
+		case 3, 4: // ctrl-c, ctrl-d
+			erase()
+			return nil, errPickerCancelled
+		case 27: // escape, alone or starting an arrow key sequence
+			if reader.Buffered() == 0 {
+				erase()
+				return nil, errPickerCancelled
+			}
+			next, _, _ := reader.ReadRune()
+			if next != '[' && next != 'O' {
+				continue
+			}
+			key, _, _ := reader.ReadRune()
+			switch key {
+			case 'A':
+				if cursor > 0 {
+					cursor--
+				}
+			case 'B':
+				if cursor < len(matches)-1 {
+					cursor++
+				}
+			}
+		case 16: // ctrl-p
+			if cursor > 0 {
+				cursor--
+			}
+		case 14: // ctrl-n
+			if cursor < len(matches)-1 {
+				cursor++
+			}
+		case '\t':
+			if multi && len(matches) > 0 {
+				selected[matches[cursor]] = !selected[matches[cursor]]
+				if cursor < len(matches)-1 {
+					cursor++
+				}
+			}
+		case '\r', '\n':
+			var chosen []int
+			for i := range items {
+				if selected[i] {
+					chosen = append(chosen, i)
+				}
+			}
+			if len(chosen) == 0 && len(matches) > 0 {
+				chosen = []int{matches[cursor]}
+			}
+			if len(chosen) == 0 {
+				continue
+			}
+			erase()
+			return chosen, nil
+		case 127, 8: // backspace
+			if query != "" {
+				_, size := utf8.DecodeLastRuneInString(query)
+				query = query[:len(query)-size]
+			}
+		case 21: // ctrl-u
+			query = ""
+		default:
+			if !unicode.IsPrint(r) {
+				continue
+			}
+			query += string(r)
+		}
+
+		matches = fuzzyFilter(items, query)
+		if cursor >= len(matches) {
+			cursor = len(matches) - 1
+		}
+		if cursor < 0 {
+			cursor = 0
+		}
+		draw()
+	}
+}
+
+// fuzzyFilter returns the indices of the items containing the characters of
+// query in order, best matches first: those whose characters are closest
+// together and nearest the start.
+func fuzzyFilter(items []string, query string) []int {
+	type match struct {
+		index int
+		score int
+	}
+
+	var matches []match
+	for i, item := range items {
+		if score, ok := fuzzyScore(item, query); ok {
+			matches = append(matches, match{i, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score < matches[j].score
+	})
+
+	indices := make([]int, len(matches))
+	for i, m := range matches {
+		indices[i] = m.index
+	}
+	return indices
+}
+
+// fuzzyScore matches query against item case-insensitively, lower scores
+// being better matches.
+func fuzzyScore(item string, query string) (int, bool) {
+	item = strings.ToLower(item)
+	query = strings.ToLower(query)
+
+	score := 0
+	last := -1
+	for _, r := range query {
+		i := strings.IndexRune(item[last+1:], r)
+		if i < 0 {
+			return 0, false
+		}
+		if last < 0 {
+			score += i
+		} else {
+			score += i * 2
+		}
+		last += i + utf8.RuneLen(r)
+	}
+	return score, true
+}
+
+// confirm asks a yes or no question on the terminal, defaulting to no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		fmt.Println()
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// provisionStatusPath is where create records the outcome of each setup
+// step inside the container, so that it can be read back even when the
+// container is stopped.
+const provisionStatusPath = "/etc/dockerbx/provisioning.json"
+
+// createFlagsLabel is the label holding the flags a container was created
+// with, as a JSON object.
+const createFlagsLabel = "create_flags"
+
+type provisionStep struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// provisionRecord lists the setup steps run when a container was created.
+type provisionRecord struct {
+	Steps    []provisionStep `json:"steps"`
+	Finished time.Time       `json:"finished"`
+}
+
+// add records the outcome of a step that was run to completion.
+func (r *provisionRecord) add(name string, err error) {
+	step := provisionStep{Name: name, Status: "ok"}
+	if err != nil {
+		step.Status = "failed"
+		step.Error = err.Error()
+	}
+	r.Steps = append(r.Steps, step)
+}
+
+// started records a step that was started in the background, whose outcome
+// is not known.
+func (r *provisionRecord) started(name string) {
+	r.Steps = append(r.Steps, provisionStep{Name: name, Status: "started"})
+}
+
+// saveProvisioning writes the record to the container, printing a warning
+// if that fails. It is meant to be deferred once the container has started.
+func saveProvisioning(ctx context.Context, cli *client.Client, containerID string, record *provisionRecord) {
+	record.Finished = time.Now()
+	data, err := json.Marshal(record)
+	if err == nil {
+		err = runSetupScript(ctx, cli, containerID,
+			`mkdir -p "$(dirname "$DOCKERBX_STATUS_PATH")" && printf '%s\n' "$DOCKERBX_STATUS" > "$DOCKERBX_STATUS_PATH"`,
+			[]string{"DOCKERBX_STATUS=" + string(data), "DOCKERBX_STATUS_PATH=" + provisionStatusPath})
+	}
+	if err != nil {
+		fmt.Printf("Warning: could not record provisioning status: %v\n", err)
+	}
+}
+
+// loadProvisioning reads the record back from the container. It returns nil
+// for containers created before the record was kept.
+func loadProvisioning(ctx context.Context, cli *client.Client, containerID string) (*provisionRecord, error) {
+	reader, _, err := cli.CopyFromContainer(ctx, containerID, provisionStatusPath)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer reader.Close()
+
+	archive := tar.NewReader(reader)
+	if _, err := archive.Next(); err != nil {
+		return nil, err
+	}
+
+	var record provisionRecord
+	if err := json.NewDecoder(archive).Decode(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// createFlags returns the flags set on the command line, encoded for the
+// create_flags label. Labels can be read by anyone with access to Docker, so
+// credentials in URLs such as the --clone one are left out.
+func createFlags(cmd *cobra.Command) string {
+	flags := map[string]string{}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		flags[f.Name] = redactURL(f.Value.String())
+	})
+	if len(flags) == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(flags)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// redactURL removes the user information from a URL, which may hold a
+// password or token. Anything that is not such a URL is returned as is.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.User == nil {
+		return value
+	}
+	u.User = nil
+	return u.String()
+}
@@ -0,0 +1,271 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func PruneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove unused dockerbx containers, images and networks",
+		Long: `Remove the resources dockerbx has left behind: containers that were never
+started, dead containers, "-updated" containers orphaned by a failed update,
+images built with dockerbx build that no remaining container uses and the
+dockerbx-network when nothing is attached to it.
+
+Stopped environments, including those stopped by idle_stop, are kept unless
+--stopped is given, since removing them loses everything installed in them.
+Running containers are never touched.
+
+--until keeps everything created after the given time, which may be a duration
+such as 72h or a date such as 2026-01-31.`,
+		Run: runPrune,
+	}
+
+	cmd.Flags().String("until", "", "Only prune resources created before this duration ago or date")
+	cmd.Flags().Bool("stopped", false, "Also remove stopped environments and everything installed in them")
+	cmd.Flags().Bool("dry-run", false, "Show what would be removed without removing anything")
+	cmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
+
+	return cmd
+}
+
+// pruneCandidate is a resource prune would remove.
+type pruneCandidate struct {
+	Kind   string
+	ID     string
+	Name   string
+	Size   int64
+	Reason string
+}
+
+func runPrune(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		fmt.Printf("Error creating Docker client: %v\n", err)
+		return
+	}
+
+	until, _ := cmd.Flags().GetString("until")
+	stopped, _ := cmd.Flags().GetBool("stopped")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	cutoff := time.Now()
+	if until != "" {
+		cutoff, err = parseUntil(until)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+
+	candidates, err := findPruneCandidates(ctx, cli, cutoff, stopped)
+	if err != nil {
+		fmt.Printf("Error finding dockerbx resources: %v\n", err)
+		return
+	}
+
+	if len(candidates) == 0 {
+		fmt.Println("Nothing to prune.")
+		return
+	}
+
+	printPruneCandidates(candidates)
+	if dryRun {
+		return
+	}
+
+	if !yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Println("Error: Refusing to prune without confirmation, use --yes when stdin is not a terminal.")
+			os.Exit(1)
+		}
+		if !confirm("Remove these resources?") {
+			fmt.Println("Aborted.")
+			return
+		}
+	}
+
+	var reclaimed int64
+	failed := 0
+	for _, c := range candidates {
+		if err := pruneResource(ctx, cli, c); err != nil {
+			fmt.Printf("Error removing %s %s: %v\n", c.Kind, c.Name, err)
+			failed++
+			continue
+		}
+		reclaimed += c.Size
+		fmt.Printf("Removed %s %s\n", c.Kind, c.Name)
+	}
+
+	fmt.Printf("\nReclaimed %s.\n", units.HumanSizeWithPrecision(float64(reclaimed), 3))
+	if failed > 0 {
+		fmt.Printf("Failed to remove %d of %d resources.\n", failed, len(candidates))
+		os.Exit(1)
+	}
+}
+
+// parseUntil turns a duration ago or a date into the cutoff time.
+func parseUntil(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --until %q, use a duration such as 72h or a date such as 2026-01-31", value)
+}
+
+// findPruneCandidates lists the dockerbx resources created before cutoff
+// that nothing still needs. Stopped containers are only included with
+// stopped. Images count as unused when only containers that are about to be
+// pruned use them.
+func findPruneCandidates(ctx context.Context, cli *client.Client, cutoff time.Time, stopped bool) ([]pruneCandidate, error) {
+	usage, err := cli.DiskUsage(ctx, types.DiskUsageOptions{
+		Types: []types.DiskUsageObject{types.ContainerObject, types.ImageObject},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []pruneCandidate
+	names := map[string]bool{}
+	for _, c := range usage.Containers {
+		names[containerName(*c)] = true
+	}
+
+	usedImages := map[string]bool{}
+	for _, c := range usage.Containers {
+		name := containerName(*c)
+		reason := ""
+		switch {
+		case c.State == "running" || c.State == "paused" || c.State == "restarting":
+		case strings.HasSuffix(name, "-updated") && names[strings.TrimSuffix(name, "-updated")]:
+			reason = "orphaned by a failed update"
+		case c.State == "created":
+			reason = "never started"
+		case c.State == "dead":
+			reason = "dead"
+		case c.State == "exited" && stopped:
+			reason = "stopped"
+		}
+		prunable := reason != "" && c.Labels["owned_by"] == "dockerbx" && time.Unix(c.Created, 0).Before(cutoff)
+
+		if !prunable {
+			usedImages[c.ImageID] = true
+			continue
+		}
+
+		candidates = append(candidates, pruneCandidate{Kind: "container", ID: c.ID, Name: name, Size: c.SizeRw, Reason: reason})
+	}
+
+	for _, img := range usage.Images {
+		if img.Labels["owned_by"] != "dockerbx" || usedImages[img.ID] || !time.Unix(img.Created, 0).Before(cutoff) {
+			continue
+		}
+		name := shortID(strings.TrimPrefix(img.ID, "sha256:"))
+		if len(img.RepoTags) > 0 && img.RepoTags[0] != "<none>:<none>" {
+			name = img.RepoTags[0]
+		}
+		size := img.Size
+		if img.SharedSize > 0 {
+			size -= img.SharedSize
+		}
+		candidates = append(candidates, pruneCandidate{Kind: "image", ID: img.ID, Name: name, Size: size, Reason: "built by dockerbx, unused"})
+	}
+
+	networks, err := cli.NetworkList(ctx, network.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", "com.dockerbx.network=true")),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range networks {
+		details, err := cli.NetworkInspect(ctx, n.ID, network.InspectOptions{})
+		if err != nil {
+			return nil, err
+		}
+		attached := 0
+		for id := range details.Containers {
+			if !containerPruned(candidates, id) {
+				attached++
+			}
+		}
+		if attached > 0 || !details.Created.Before(cutoff) {
+			continue
+		}
+		candidates = append(candidates, pruneCandidate{Kind: "network", ID: n.ID, Name: n.Name, Reason: "no containers attached"})
+	}
+
+	return candidates, nil
+}
+
+func containerPruned(candidates []pruneCandidate, id string) bool {
+	for _, c := range candidates {
+		if c.Kind == "container" && c.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func printPruneCandidates(candidates []pruneCandidate) {
+	nameWidth := len("NAME") + 2
+	for _, c := range candidates {
+		if len(c.Name)+2 > nameWidth {
+			nameWidth = len(c.Name) + 2
+		}
+	}
+
+	var total int64
+	format := fmt.Sprintf("%%-12s%%-%ds%%-12s%%s\n", nameWidth)
+	fmt.Printf(format, "TYPE", "NAME", "SIZE", "REASON")
+	for _, c := range candidates {
+		size := "-"
+		if c.Kind != "network" {
+			size = units.HumanSizeWithPrecision(float64(c.Size), 3)
+		}
+		fmt.Printf(format, c.Kind, c.Name, size, c.Reason)
+		total += c.Size
+	}
+	fmt.Printf("\nTotal reclaimable space: %s\n", units.HumanSizeWithPrecision(float64(total), 3))
+}
+
+// pruneResource removes one candidate. The candidates are ordered so that
+// containers go before the images and networks they use.
+func pruneResource(ctx context.Context, cli *client.Client, c pruneCandidate) error {
+	switch c.Kind {
+	case "container":
+		if err := cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{}); err != nil {
+			return err
+		}
+		if _, err := removeExports(c.Name, nil); err != nil {
+			fmt.Printf("Error removing exported binaries: %v\n", err)
+		}
+		return nil
+	case "image":
+		_, err := cli.ImageRemove(ctx, c.ID, image.RemoveOptions{PruneChildren: true})
+		return err
+	case "network":
+		return cli.NetworkRemove(ctx, c.ID)
+	}
+	return fmt.Errorf("unknown resource type %q", c.Kind)
+}
@@ -28,6 +28,8 @@ func PythonCmd() *cobra.Command {
 	cmd.Flags().String("venv", "", "Name of the virtual environment to create")
 	cmd.Flags().StringSlice("packages", []string{}, "List of packages to install")
 	cmd.Flags().String("requirements", "", "Path to requirements.txt file")
+	cmd.Flags().String("profile", "", "Configuration profile to use for the container")
+	cmd.Flags().String("shell", "", "Shell to start on enter, recorded on the container; default is the profile's, else detected on each enter")
 
 	return cmd
 }
@@ -87,16 +89,46 @@ func runPythonCreate(cmd *cobra.Command, args []string) {
 		})
 	}
 
+	labels := map[string]string{
+		"owned_by": "dockerbx",
+		"type":     "python",
+	}
+	profile, _ := cmd.Flags().GetString("profile")
+	if profile != "" {
+		labels["profile"] = profile
+	}
+	shell, _ := cmd.Flags().GetString("shell")
+	if shell == "" {
+		shell = config.Profile(profile).Shell
+	}
+	// only an explicit choice is recorded, a detected shell is detected
+	// again on each enter
+	if shell != "" {
+		labels["shell"] = shell
+	}
+	if flags := createFlags(cmd); flags != "" {
+		labels[createFlagsLabel] = flags
+	}
+
+	// the bridge is optional, so a socket directory that cannot be
+	// prepared only leaves dockerbx-host-exec unusable
+	var bridgeErr error
+	if len(config.HostExec.Allow) > 0 {
+		bridgeMount, err := hostExecMount()
+		if err != nil {
+			bridgeErr = fmt.Errorf("preparing the socket directory: %w", err)
+		} else {
+			mounts = append(mounts, bridgeMount)
+		}
+	}
+
 	resp, err := cli.ContainerCreate(ctx,
 		&container.Config{
-			Image: baseImage,
-			Cmd:   []string{"/bin/bash"},
-			Tty:   true,
-			Labels: map[string]string{
-				"owned_by": "dockerbx",
-				"type":     "python",
-			},
-			Env: []string{"PS1=\\[\\e[32m\\]⬢\\[\\e[0m\\][\\u@dockerbx-python](\\W)\\$ "},
+			Image:  baseImage,
+			Cmd:    []string{"/bin/bash"},
+			Tty:    true,
+			Labels: labels,
+			Env:    append([]string{"PS1=\\[\\e[32m\\]⬢\\[\\e[0m\\][\\u@dockerbx-python](\\W)\\$ "}, timezoneAndLocaleEnv(config)...),
 		},
 		&container.HostConfig{
 			Mounts: mounts,
@@ -117,19 +149,42 @@ func runPythonCreate(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	provisioning := &provisionRecord{}
+	defer saveProvisioning(ctx, cli, resp.ID, provisioning)
+
+	err = setupTimezoneAndLocale(ctx, cli, resp.ID, config)
+	provisioning.add("timezone-locale", err)
+	if err != nil {
+		fmt.Printf("Warning: could not set up timezone and locale: %v\n", err)
+	}
+
+	if len(config.HostExec.Allow) > 0 {
+		err = bridgeErr
+		if err == nil {
+			err = setupHostExec(ctx, cli, resp.ID)
+		}
+		provisioning.add("host-exec", err)
+		if err != nil {
+			fmt.Printf("Warning: could not set up dockerbx-host-exec: %v\n", err)
+		}
+	}
+
 	if venvName != "" {
 		createVenvCmd := fmt.Sprintf("python -m venv %s", venvName)
 		execResp, err := cli.ContainerExecCreate(ctx, resp.ID, types.ExecConfig{
 			Cmd: []string{"/bin/sh", "-c", createVenvCmd},
 		})
 		if err != nil {
+			provisioning.add("venv", err)
 			fmt.Printf("Error creating virtual environment: %v\n", err)
 			return
 		}
 		if err := cli.ContainerExecStart(ctx, execResp.ID, types.ExecStartCheck{}); err != nil {
+			provisioning.add("venv", err)
 			fmt.Printf("Error starting virtual environment creation: %v\n", err)
 			return
 		}
+		provisioning.started("venv")
 		fmt.Printf("Virtual environment '%s' created\n", venvName)
 	}
 
@@ -148,13 +203,16 @@ func runPythonCreate(cmd *cobra.Command, args []string) {
 			Cmd: []string{"/bin/sh", "-c", installCmd},
 		})
 		if err != nil {
+			provisioning.add("packages", err)
 			fmt.Printf("Error creating package installation command: %v\n", err)
 			return
 		}
 		if err := cli.ContainerExecStart(ctx, execResp.ID, types.ExecStartCheck{}); err != nil {
+			provisioning.add("packages", err)
 			fmt.Printf("Error starting package installation: %v\n", err)
 			return
 		}
+		provisioning.started("packages")
 		if len(packages) > 0 {
 			fmt.Printf("Packages installed: %s\n", strings.Join(packages, ", "))
 		}
@@ -172,13 +230,16 @@ func runPythonCreate(cmd *cobra.Command, args []string) {
 			Cmd: []string{"/bin/sh", "-c", cloneCmd},
 		})
 		if err != nil {
+			provisioning.add("clone", err)
 			fmt.Printf("Error creating clone command: %v\n", err)
 			return
 		}
 		if err := cli.ContainerExecStart(ctx, execResp.ID, types.ExecStartCheck{}); err != nil {
+			provisioning.add("clone", err)
 			fmt.Printf("Error starting clone: %v\n", err)
 			return
 		}
+		provisioning.started("clone")
 		fmt.Printf("Repository cloned: %s\n", cloneURL)
 	}
 }
@@ -3,10 +3,13 @@ package commands
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 func RemoveCmd() *cobra.Command {
@@ -18,6 +21,8 @@ func RemoveCmd() *cobra.Command {
 
 	cmd.Flags().BoolP("force", "f", false, "Force removal of running containers")
 	cmd.Flags().BoolP("all", "a", false, "Remove all dockerbx containers")
+	cmd.Flags().Bool("ignore-ownership", false, "Remove containers even if they were not created by dockerbx")
+	cmd.Flags().BoolP("yes", "y", false, "Don't ask for confirmation")
 
 	return cmd
 }
@@ -32,51 +37,134 @@ func runRemove(cmd *cobra.Command, args []string) {
 
 	force, _ := cmd.Flags().GetBool("force")
 	all, _ := cmd.Flags().GetBool("all")
+	ignoreOwnership, _ := cmd.Flags().GetBool("ignore-ownership")
+	yes, _ := cmd.Flags().GetBool("yes")
 
 	var containerNames []string
 	if all {
-		containers, err := ListDockerBxContainers(ctx, cli)
+		containers, err := ListDockerBxContainers(ctx, cli, container.ListOptions{})
 		if err != nil {
 			fmt.Printf("Error listing containers: %v\n", err)
 			return
 		}
 
 		for _, c := range containers {
-			containerNames = append(containerNames, c.ID)
+			containerNames = append(containerNames, containerName(c))
 		}
 	} else if len(args) > 0 {
 		containerNames = args
+	} else if term.IsTerminal(int(os.Stdin.Fd())) {
+		containerNames, err = pickContainers(ctx, cli, "Remove", true)
+		if err != nil {
+			fmt.Printf("Error removing containers: %v\n", err)
+			return
+		}
 	} else {
 		fmt.Printf("Error removing containers: No container name(s) provided.\n")
 		return
 	}
 
-	for _, containerName := range containerNames {
-		containerJSON, err := cli.ContainerInspect(ctx, containerName)
-		if err != nil {
-			fmt.Printf("Error inspecting container: %v\n", err)
+	if len(containerNames) == 0 {
+		fmt.Println("No containers to remove.")
+		return
+	}
+
+	if !yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Println("Error: Refusing to remove containers without confirmation, use --yes when stdin is not a terminal.")
+			os.Exit(1)
+		}
+		fmt.Println("The following containers will be removed:")
+		for _, containerName := range containerNames {
+			fmt.Printf("  %s\n", describeRemoval(ctx, cli, containerName, force))
+		}
+		if !confirm("Continue?") {
+			fmt.Println("Aborted.")
 			return
 		}
+	}
 
-		if containerJSON.State.Running && !force {
-			fmt.Printf("Error removing container: Container is running. Use -f to force remove.\n")
-			return
+	type removeFailure struct {
+		name string
+		err  error
+	}
+	var failures []removeFailure
+	for _, containerName := range containerNames {
+		if err := removeContainer(ctx, cli, containerName, force, ignoreOwnership); err != nil {
+			fmt.Printf("Error removing container \"%v\": %v\n", containerName, err)
+			failures = append(failures, removeFailure{containerName, err})
 		}
+	}
 
-		if containerJSON.State.Running && force {
-			err = cli.ContainerStop(ctx, containerJSON.ID, container.StopOptions{})
-			if err != nil {
-				fmt.Printf("Error stoping container: %v\n", err)
-				return
-			}
+	if len(failures) > 0 {
+		fmt.Printf("\nFailed to remove %d of %d containers:\n", len(failures), len(containerNames))
+		for _, failure := range failures {
+			fmt.Printf("  %s: %v\n", failure.name, failure.err)
 		}
+		os.Exit(1)
+	}
+}
 
-		err = cli.ContainerRemove(ctx, containerJSON.ID, container.RemoveOptions{Force: force})
+// describeRemoval names a container in the confirmation prompt, noting
+// whether it has to be stopped or will be refused.
+func describeRemoval(ctx context.Context, cli *client.Client, containerName string, force bool) string {
+	containerJSON, err := cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return containerName + " (not found)"
+	}
+
+	details := []string{containerJSON.Config.Image}
+	if containerJSON.State.Running {
+		if force {
+			details = append(details, "running, will be stopped")
+		} else {
+			details = append(details, "running")
+		}
+	}
+	if containerJSON.Config.Labels["owned_by"] != "dockerbx" {
+		details = append(details, "not a dockerbx container")
+	}
+	return fmt.Sprintf("%s (%s)", strings.TrimPrefix(containerJSON.Name, "/"), strings.Join(details, ", "))
+}
+
+// removeContainer removes one dockerbx container and the binaries exported
+// from it. Containers not labelled as owned by dockerbx are refused unless
+// ignoreOwnership is set.
+func removeContainer(ctx context.Context, cli *client.Client, containerName string, force bool, ignoreOwnership bool) error {
+	containerJSON, err := cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		return fmt.Errorf("inspecting container: %w", err)
+	}
+
+	if containerJSON.Config.Labels["owned_by"] != "dockerbx" && !ignoreOwnership {
+		return fmt.Errorf("not a dockerbx container, use --ignore-ownership to remove it anyway")
+	}
+
+	if containerJSON.State.Running && !force {
+		return fmt.Errorf("container is running, use -f to force remove")
+	}
+
+	if containerJSON.State.Running && force {
+		err = cli.ContainerStop(ctx, containerJSON.ID, container.StopOptions{})
 		if err != nil {
-			fmt.Printf("Error removing container: %v\n", err)
-			return
+			return fmt.Errorf("stopping container: %w", err)
 		}
+	}
+
+	err = cli.ContainerRemove(ctx, containerJSON.ID, container.RemoveOptions{Force: force})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully removed container \"%v\"\n", containerName)
 
-		fmt.Printf("Successfully removed container \"%v\"\n", containerName)
+	removed, err := removeExports(strings.TrimPrefix(containerJSON.Name, "/"), nil)
+	if err != nil {
+		fmt.Printf("Error removing exported binaries: %v\n", err)
 	}
+	for _, export := range removed {
+		fmt.Printf("Removed exported binary %s\n", export.Shim)
+	}
+
+	return nil
 }
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/albertoperdomo2/dockerbx/internal/asciicast"
+	"github.com/spf13/cobra"
+)
+
+func ReplayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay [file_name]",
+		Short: "Play back a session recorded with enter --record",
+		Args:  cobra.ExactArgs(1),
+		Run:   runReplay,
+	}
+
+	cmd.Flags().Float64P("speed", "s", 1, "Playback speed multiplier")
+	cmd.Flags().Duration("idle-limit", 0, "Cap pauses between events to this duration, e.g. 2s")
+
+	return cmd
+}
+
+func runReplay(cmd *cobra.Command, args []string) {
+	speed, _ := cmd.Flags().GetFloat64("speed")
+	idleLimit, _ := cmd.Flags().GetDuration("idle-limit")
+	if speed <= 0 {
+		fmt.Println("Error: Speed must be greater than zero.")
+		return
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		fmt.Printf("Error opening recording: %v\n", err)
+		return
+	}
+	defer file.Close()
+
+	header, reader, err := asciicast.NewReader(file)
+	if err != nil {
+		fmt.Printf("Error reading recording: %v\n", err)
+		return
+	}
+
+	if size := terminalSize(); size != nil && (int(size[1]) < header.Width || int(size[0]) < header.Height) {
+		fmt.Printf("Warning: the recording is %dx%d but the terminal is %dx%d, output may wrap.\n", header.Width, header.Height, size[1], size[0])
+	}
+
+	last := 0.0
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Printf("\nError reading recording: %v\n", err)
+			return
+		}
+
+		delay := time.Duration((event.Time - last) / speed * float64(time.Second))
+		if idleLimit > 0 && delay > idleLimit {
+			delay = idleLimit
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		last = event.Time
+
+		if event.Type == asciicast.EventOutput {
+			os.Stdout.WriteString(event.Data)
+		}
+	}
+}
@@ -3,100 +3,276 @@ package commands
 import (
 	"context"
 	"fmt"
-	"io"
 	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/albertoperdomo2/dockerbx/internal/config"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	// timeoutExitCode is returned when --timeout stops the command, as
+	// coreutils timeout does.
+	timeoutExitCode = 124
+	// stopGracePeriod is how long a timed out command gets to exit after
+	// SIGTERM before it is killed.
+	stopGracePeriod = 5 * time.Second
 )
 
 func RunCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:                "run [container_name] [-t] [command]",
-		Short:              "Run a command in a container",
-		DisableFlagParsing: true,
-		Run:                runRun,
+		Use:   "run [flags] [container_name[,container_name...]] command [args...]",
+		Short: "Run a command in a container",
+		Long: `Run a command in a container without entering it.
+
+dockerbx's own flags come before the container name, and everything after the
+container name is the command, flags included. The container name is optional
+and defaults to default_name from the config, in which case "--" separates
+the command:
+
+  dockerbx run my-env ls -la
+  dockerbx run -u root -w /tmp my-env ls -la
+  dockerbx run -u root -- ls -la
+
+Given several containers separated by commas, or a selector such as --all,
+--label or --profile, the command runs in all of them in parallel, each output
+line is prefixed with the container name and a summary of exit codes is
+printed at the end:
+
+  dockerbx run env1,env2 make test
+  dockerbx run --all -- dnf check-update
+
+A "--" only separates the command when it comes before it, either in place of
+the container name or right after it, so the command's own "--" is left alone:
+
+  dockerbx run my-env git log -- README.md`,
+		Run: runRun,
 	}
 
+	// the command's own flags must not be taken for ours
+	cmd.Flags().SetInterspersed(false)
+
+	cmd.Flags().StringP("user", "u", "", "User to run as inside the container (name|uid[:group|gid])")
+	cmd.Flags().StringP("workdir", "w", "", "Working directory inside the container, default matches the host directory")
+	cmd.Flags().StringArrayP("env", "e", []string{}, "Set environment variables (KEY=value, or KEY to copy it from the host)")
+	cmd.Flags().BoolP("interactive", "i", false, "Forward stdin even when it is a terminal")
+	cmd.Flags().BoolP("tty", "t", false, "Allocate a TTY for the command")
+	cmd.Flags().Duration("timeout", 0, "Stop the command if it runs longer than this, e.g. 10m (exit status 124)")
+	cmd.Flags().BoolP("all", "a", false, "Run in all dockerbx containers")
+	cmd.Flags().StringArrayP("label", "l", []string{}, "Run in the dockerbx containers with this label (key or key=value)")
+	cmd.Flags().String("profile", "", "Run in the dockerbx containers created with this profile")
+	cmd.Flags().IntP("parallel", "P", 4, "Maximum number of containers to run in at once")
+
 	return cmd
 }
 
 func runRun(cmd *cobra.Command, args []string) {
-	if len(args) < 2 {
-		fmt.Println("Error: Please provide both a container name and a command to run.")
-		return
+	all, _ := cmd.Flags().GetBool("all")
+	labels, _ := cmd.Flags().GetStringArray("label")
+	profile, _ := cmd.Flags().GetString("profile")
+	selecting := all || len(labels) > 0 || profile != ""
+
+	containerArgs, command := splitRunArgs(args, cmd.ArgsLenAtDash(), selecting)
+	if len(command) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: Please provide a command to run.")
+		os.Exit(1)
 	}
 
-	containerName := args[0]
-	command := args[1:]
+	// the config is only needed for the default name, so a missing config
+	// is not fatal when the container is named
+	defaultName := ""
+	cfg, err := config.LoadConfig()
+	if err == nil {
+		defaultName = cfg.DefaultName
+		ensureIdleWatcher(cfg)
+	}
 
 	ctx := context.Background()
 	cli, err := client.NewClientWithOpts(client.FromEnv)
 	if err != nil {
-		fmt.Printf("Error creating Docker client: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error creating Docker client: %v\n", err)
+		os.Exit(1)
+	}
+
+	if selecting || len(containerArgs) > 1 {
+		runRunFanout(ctx, cli, cmd, containerArgs, command)
 		return
 	}
 
+	containerName, err := resolveContainerName(ctx, cli, containerArgs, defaultName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	containerJSON, err := cli.ContainerInspect(ctx, containerName)
 	if err != nil {
-		fmt.Printf("Error: Container '%s' not found.\n", containerName)
-		return
+		fmt.Fprintf(os.Stderr, "Error: Container '%s' not found.\n", containerName)
+		os.Exit(1)
 	}
 
 	if !containerJSON.State.Running {
-		fmt.Printf("Container '%s' is not running. Starting it now...\n", containerName)
+		// keep stdout for the command, e.g. when run from an export-bin shim
+		fmt.Fprintf(os.Stderr, "Container '%s' is not running. Starting it now...\n", containerName)
 		err = cli.ContainerStart(ctx, containerName, container.StartOptions{})
 		if err != nil {
-			fmt.Printf("Error starting container: %v\n", err)
-			return
+			fmt.Fprintf(os.Stderr, "Error starting container: %v\n", err)
+			os.Exit(1)
 		}
 	}
 
-	tty := false
-	if command[0] == "--tty" || command[0] == "-t" {
-		tty = true
-		command = command[1:]
-	}
+	user, _ := cmd.Flags().GetString("user")
+	workdirOverride, _ := cmd.Flags().GetString("workdir")
+	envFlags, _ := cmd.Flags().GetStringArray("env")
+	interactive, _ := cmd.Flags().GetBool("interactive")
+	tty, _ := cmd.Flags().GetBool("tty")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
 
+	// forward stdin when asked to, when something is piped in, or when a
+	// TTY is requested
+	stdinIsTerminal := term.IsTerminal(int(os.Stdin.Fd()))
+
+	marker := newExecMarker()
 	execConfig := types.ExecConfig{
 		Cmd:          command,
+		User:         user,
+		AttachStdin:  interactive || tty || !stdinIsTerminal,
 		AttachStdout: true,
 		AttachStderr: true,
 		Tty:          tty,
+		Env:          append(envVars(envFlags), marker),
+		WorkingDir:   resolveWorkdir(ctx, cli, containerJSON, user, workdirOverride),
+	}
+	if tty {
+		execConfig.Env = append(terminalEnv(), execConfig.Env...)
+		execConfig.ConsoleSize = terminalSize()
 	}
 
 	execID, err := cli.ContainerExecCreate(ctx, containerName, execConfig)
 	if err != nil {
-		fmt.Printf("Error creating exec instance: %v\n", err)
-		return
+		fmt.Fprintf(os.Stderr, "Error creating exec instance: %v\n", err)
+		os.Exit(1)
 	}
 
-	resp, err := cli.ContainerExecAttach(ctx, execID.ID, types.ExecStartCheck{Tty: tty})
+	stopForwarding := forwardSignals(ctx, cli, containerJSON.ID, execID.ID, marker)
+
+	var timedOut atomic.Bool
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			timedOut.Store(true)
+			fmt.Fprintf(os.Stderr, "Command timed out after %s, stopping it...\n", timeout)
+			terminateExec(ctx, cli, containerJSON.ID, execID.ID, marker, stopGracePeriod)
+		})
+		defer timer.Stop()
+	}
+
+	exitCode, err := attachExec(ctx, cli, execID.ID, attachOptions{
+		Tty:         tty,
+		ConsoleSize: execConfig.ConsoleSize,
+		Stdin:       execConfig.AttachStdin,
+	})
+	stopForwarding()
 	if err != nil {
-		fmt.Printf("Error attaching to exec instance: %v\n", err)
-		return
+		// don't leave the command running without anyone attached to it
+		if execRunning(ctx, cli, execID.ID) {
+			signalExec(ctx, cli, containerJSON.ID, marker, "KILL")
+		}
+		fmt.Fprintf(os.Stderr, "Error running command: %v\n", err)
+		os.Exit(1)
 	}
-	defer resp.Close()
 
-	if tty {
-		_, err = io.Copy(os.Stdout, resp.Reader)
-	} else {
-		_, err = io.Copy(os.Stdout, resp.Reader)
+	if timedOut.Load() {
+		os.Exit(timeoutExitCode)
 	}
-	if err != nil && err != io.EOF {
-		fmt.Printf("Error streaming command output: %v\n", err)
+
+	if exitCode != 0 {
+		fmt.Fprintf(os.Stderr, "Command exited with non-zero status: %d\n", exitCode)
+		os.Exit(exitCode)
 	}
+}
 
-	inspectResp, err := cli.ContainerExecInspect(ctx, execID.ID)
-	if err != nil {
-		fmt.Printf("Error inspecting exec instance: %v\n", err)
-		return
+// splitRunArgs separates the container names from the command. Flag parsing
+// stops at the first positional argument, so dash, where pflag found "--",
+// is either 0 or -1. Otherwise the first argument names the containers, and
+// a "--" right after it is dropped.
+func splitRunArgs(args []string, dash int, selecting bool) (containerArgs, command []string) {
+	switch {
+	case dash >= 0:
+		return args[:dash], args[dash:]
+	case selecting:
+		return nil, args
+	case len(args) == 0:
+		return nil, nil
+	}
+
+	for _, name := range strings.Split(args[0], ",") {
+		if name != "" && !slices.Contains(containerArgs, name) {
+			containerArgs = append(containerArgs, name)
+		}
+	}
+	command = args[1:]
+	if len(command) > 0 && command[0] == "--" {
+		command = command[1:]
 	}
+	return containerArgs, command
+}
+
+// runRunFanout runs the command in several containers in parallel and exits
+// non-zero unless it succeeded in all of them.
+func runRunFanout(ctx context.Context, cli *client.Client, cmd *cobra.Command, containerArgs []string, command []string) {
+	all, _ := cmd.Flags().GetBool("all")
+	labels, _ := cmd.Flags().GetStringArray("label")
+	profile, _ := cmd.Flags().GetString("profile")
+	interactive, _ := cmd.Flags().GetBool("interactive")
+	tty, _ := cmd.Flags().GetBool("tty")
+
+	if interactive || tty {
+		fmt.Println("Error: --interactive and --tty cannot be used when running in several containers.")
+		os.Exit(1)
+	}
+
+	containerNames := containerArgs
+	if all || len(labels) > 0 || profile != "" {
+		selected, err := selectContainers(ctx, cli, all, labels, profile)
+		if err != nil {
+			fmt.Printf("Error listing containers: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range selected {
+			if !slices.Contains(containerNames, name) {
+				containerNames = append(containerNames, name)
+			}
+		}
+	}
+
+	if len(containerNames) == 0 {
+		fmt.Println("No containers matched.")
+		os.Exit(1)
+	}
+
+	user, _ := cmd.Flags().GetString("user")
+	workdir, _ := cmd.Flags().GetString("workdir")
+	envFlags, _ := cmd.Flags().GetStringArray("env")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	parallel, _ := cmd.Flags().GetInt("parallel")
+
+	results := runFanout(ctx, cli, containerNames, fanoutOptions{
+		Command:  command,
+		User:     user,
+		Workdir:  workdir,
+		Env:      envVars(envFlags),
+		Timeout:  timeout,
+		Parallel: parallel,
+	})
 
-	if inspectResp.ExitCode != 0 {
-		fmt.Printf("Command exited with non-zero status: %d\n", inspectResp.ExitCode)
-		os.Exit(inspectResp.ExitCode)
+	if !printFanoutSummary(results) {
+		os.Exit(1)
 	}
 }
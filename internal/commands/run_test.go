@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRunArgs(t *testing.T) {
+	tests := []struct {
+		args      []string
+		all       bool
+		user      string
+		container []string
+		command   []string
+	}{
+		{
+			args:      []string{"myenv", "ls", "-a"},
+			container: []string{"myenv"},
+			command:   []string{"ls", "-a"},
+		},
+		{
+			args:      []string{"myenv", "sort", "-u", "file"},
+			container: []string{"myenv"},
+			command:   []string{"sort", "-u", "file"},
+		},
+		{
+			args:      []string{"-u", "root", "myenv", "ls", "-la"},
+			user:      "root",
+			container: []string{"myenv"},
+			command:   []string{"ls", "-la"},
+		},
+		{
+			args:    []string{"-u", "root", "--", "ls", "-la"},
+			user:    "root",
+			command: []string{"ls", "-la"},
+		},
+		{
+			args:      []string{"env1,env2", "make", "-j4"},
+			container: []string{"env1", "env2"},
+			command:   []string{"make", "-j4"},
+		},
+		{
+			args:      []string{"myenv", "git", "log", "--", "README.md"},
+			container: []string{"myenv"},
+			command:   []string{"git", "log", "--", "README.md"},
+		},
+		{
+			args:      []string{"myenv", "--", "git", "log", "--", "README.md"},
+			container: []string{"myenv"},
+			command:   []string{"git", "log", "--", "README.md"},
+		},
+		{
+			args:      []string{"-t", "myenv", "--", "rg", "-n", "TODO"},
+			container: []string{"myenv"},
+			command:   []string{"rg", "-n", "TODO"},
+		},
+		{
+			args:    []string{"--all", "--", "dnf", "check-update"},
+			all:     true,
+			command: []string{"dnf", "check-update"},
+		},
+		{
+			args:    []string{"-a", "uname", "-a"},
+			all:     true,
+			command: []string{"uname", "-a"},
+		},
+	}
+
+	for _, tt := range tests {
+		cmd := RunCmd()
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		all, _ := cmd.Flags().GetBool("all")
+		user, _ := cmd.Flags().GetString("user")
+		container, command := splitRunArgs(cmd.Flags().Args(), cmd.ArgsLenAtDash(), all)
+
+		if all != tt.all || user != tt.user {
+			t.Errorf("%q: got --all=%v --user=%q, want --all=%v --user=%q", tt.args, all, user, tt.all, tt.user)
+		}
+		if !slices.Equal(container, tt.container) || !slices.Equal(command, tt.command) {
+			t.Errorf("%q: got containers %q command %q, want %q %q", tt.args, container, command, tt.container, tt.command)
+		}
+	}
+}
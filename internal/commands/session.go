@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/albertoperdomo2/dockerbx/internal/config"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// sessionSocket is the name of the tmux server that hosts dockerbx
+	// sessions, kept apart from any tmux the user runs themselves.
+	sessionSocket     = "dockerbx"
+	sessionConfigPath = "/etc/dockerbx/tmux.conf"
+)
+
+// sessionSetupScript installs tmux when missing and writes the dockerbx tmux
+// configuration, which keeps the multiplexer out of the way: no status bar,
+// a long scrollback and mouse scrolling.
+var sessionSetupScript = `
+if ! command -v tmux >/dev/null 2>&1; then` + installPackageScript("tmux") + `fi
+command -v tmux >/dev/null 2>&1 || { echo "tmux is not available"; exit 1; }
+mkdir -p "$(dirname "$DOCKERBX_TMUX_CONF")"
+cat > "$DOCKERBX_TMUX_CONF" <<'EOF'
+set -g status off
+set -g mouse on
+set -g history-limit 50000
+set -g default-terminal "tmux-256color"
+set -s escape-time 0
+EOF
+`
+
+func SessionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions [container_name]",
+		Short: "List the persistent sessions of a container",
+		Run:   runSessions,
+	}
+
+	cmd.Flags().StringP("user", "u", "", "User whose sessions to list")
+
+	return cmd
+}
+
+func runSessions(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	config, err := config.LoadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		return
+	}
+
+	containerName := config.DefaultName
+	if len(args) > 0 {
+		containerName = args[0]
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		fmt.Printf("Error creating Docker client: %v\n", err)
+		return
+	}
+
+	containerJSON, err := cli.ContainerInspect(ctx, containerName)
+	if err != nil {
+		fmt.Printf("Error: Container '%s' not found.\n", containerName)
+		return
+	}
+
+	if !containerJSON.State.Running {
+		fmt.Printf("Container '%s' is not running, it has no sessions.\n", containerName)
+		return
+	}
+
+	user, _ := cmd.Flags().GetString("user")
+	sessions, err := listSessions(ctx, cli, containerJSON.ID, user)
+	if err != nil {
+		fmt.Printf("Error listing sessions: %v\n", err)
+		return
+	}
+
+	if len(sessions) == 0 {
+		fmt.Printf("No sessions found in container '%s'.\n", containerName)
+		return
+	}
+
+	format := "%-20s%-10s%-22s%-10s\n"
+	fmt.Printf(format, "SESSION", "WINDOWS", "CREATED", "ATTACHED")
+	for _, s := range sessions {
+		attached := "no"
+		if s.Attached {
+			attached = "yes"
+		}
+		fmt.Printf(format, truncateString(s.Name, 20), strconv.Itoa(s.Windows), s.Created.Format("2006-01-02 15:04:05"), attached)
+	}
+}
+
+type session struct {
+	Name     string
+	Windows  int
+	Created  time.Time
+	Attached bool
+}
+
+// listSessions returns the sessions running on the dockerbx tmux server of
+// user inside the container.
+func listSessions(ctx context.Context, cli *client.Client, containerID string, user string) ([]session, error) {
+	exitCode, output, err := execInContainer(ctx, cli, containerID, types.ExecConfig{
+		User: user,
+		Cmd: []string{"/bin/sh", "-c",
+			`command -v tmux >/dev/null 2>&1 || exit 0; tmux -L "$0" list-sessions -F '#{session_name}	#{session_windows}	#{session_created}	#{session_attached}' 2>/dev/null; exit 0`,
+			sessionSocket},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("exit status %d: %s", exitCode, output)
+	}
+
+	var sessions []session
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			continue
+		}
+		windows, _ := strconv.Atoi(fields[1])
+		created, _ := strconv.ParseInt(fields[2], 10, 64)
+		attached, _ := strconv.Atoi(fields[3])
+		sessions = append(sessions, session{
+			Name:     fields[0],
+			Windows:  windows,
+			Created:  time.Unix(created, 0),
+			Attached: attached > 0,
+		})
+	}
+
+	return sessions, nil
+}
+
+// ensureSessionSupport makes sure the container can host dockerbx sessions.
+func ensureSessionSupport(ctx context.Context, cli *client.Client, containerID string) error {
+	return runSetupScript(ctx, cli, containerID, sessionSetupScript, []string{"DOCKERBX_TMUX_CONF=" + sessionConfigPath})
+}
+
+// sessionCommand returns the command that attaches to the named session,
+// creating it with command as its first program when it does not exist.
+func sessionCommand(name string, workdir string, env []string, command []string) []string {
+	tmuxCmd := []string{"tmux", "-L", sessionSocket, "-f", sessionConfigPath, "new-session", "-A", "-s", name}
+	if workdir != "" {
+		tmuxCmd = append(tmuxCmd, "-c", workdir)
+	}
+	for _, kv := range env {
+		tmuxCmd = append(tmuxCmd, "-e", kv)
+	}
+	return append(tmuxCmd, command...)
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// fallbackShells are tried in order when the preferred shell is unset or
+// missing from the container.
+var fallbackShells = []string{"bash", "zsh", "fish", "sh"}
+
+// shellProbeScript prints the path of the first available shell among its
+// arguments, followed by the system-wide bashrc if the image has one.
+const shellProbeScript = `
+for candidate in "$@"; do
+	found=$(command -v "$candidate" 2>/dev/null) || continue
+	echo "$found"
+	for rc in /etc/bashrc /etc/bash.bashrc; do
+		if [ -f "$rc" ]; then
+			echo "$rc"
+			break
+		fi
+	done
+	exit 0
+done
+exit 1
+`
+
+// detectShell probes the container for the preferred shell, falling back to
+// the common shells and finally to /bin/sh, and returns the command line to
+// start it interactively.
+func detectShell(ctx context.Context, cli *client.Client, containerID string, user string, preferred string) (string, []string) {
+	candidates := fallbackShells
+	if preferred != "" {
+		candidates = append([]string{preferred}, fallbackShells...)
+	}
+
+	exitCode, output, err := execInContainer(ctx, cli, containerID, types.ExecConfig{
+		User: user,
+		Cmd:  append([]string{"/bin/sh", "-c", shellProbeScript, "dockerbx-probe"}, candidates...),
+	})
+	if err != nil || exitCode != 0 || output == "" {
+		return "/bin/sh", []string{"/bin/sh"}
+	}
+
+	lines := strings.Split(output, "\n")
+	shell := strings.TrimSpace(lines[0])
+	rcFile := ""
+	if len(lines) > 1 {
+		rcFile = strings.TrimSpace(lines[1])
+	}
+
+	return shell, shellCommand(shell, rcFile)
+}
+
+// shellCommand builds the interactive command line for shell. Bash is
+// pointed at /etc/bashrc on images that ship one, since it would otherwise
+// skip it for non-login shells.
+func shellCommand(shell string, rcFile string) []string {
+	switch path.Base(shell) {
+	case "bash":
+		if rcFile == "/etc/bashrc" {
+			return []string{shell, "--rcfile", rcFile}
+		}
+		return []string{shell}
+	case "zsh", "fish":
+		return []string{shell, "-l"}
+	default:
+		return []string{shell}
+	}
+}
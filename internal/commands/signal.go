@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// execMarkerEnv tags the processes started by an exec so that they can be
+// found inside the container; ContainerExecInspect only knows the PID in the
+// host's (or Docker VM's) namespace.
+const execMarkerEnv = "DOCKERBX_EXEC_ID"
+
+// signalScript sends the signal $1 to the process started by the exec tagged
+// with marker $2: the one process carrying the marker whose parent does not.
+const signalScript = `
+marker="$2"
+for environ in /proc/[0-9]*/environ; do
+	pid=${environ#/proc/}
+	pid=${pid%/environ}
+	tr '\0' '\n' < "$environ" 2>/dev/null | grep -qx "$marker" || continue
+	ppid=0
+	while read -r key value; do
+		[ "$key" = "PPid:" ] && ppid=$value
+	done < "/proc/$pid/status"
+	if [ "$ppid" != 0 ] && tr '\0' '\n' < "/proc/$ppid/environ" 2>/dev/null | grep -qx "$marker"; then
+		continue
+	fi
+	kill -s "$1" "$pid"
+done
+`
+
+// forwardedSignals are relayed from dockerbx to the exec'd process.
+var forwardedSignals = map[os.Signal]string{
+	syscall.SIGINT:  "INT",
+	syscall.SIGTERM: "TERM",
+	syscall.SIGHUP:  "HUP",
+}
+
+// newExecMarker returns the environment entry that tags a new exec.
+func newExecMarker() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return execMarkerEnv + "=" + hex.EncodeToString(b)
+}
+
+// signalExec delivers sig (e.g. "TERM") to the process tagged with marker.
+func signalExec(ctx context.Context, cli *client.Client, containerID string, marker string, sig string) error {
+	_, _, err := execInContainer(ctx, cli, containerID, types.ExecConfig{
+		User: "root",
+		Cmd:  []string{"/bin/sh", "-c", signalScript, "dockerbx-signal", sig, marker},
+	})
+	return err
+}
+
+// execRunning reports whether the exec is still running.
+func execRunning(ctx context.Context, cli *client.Client, execID string) bool {
+	inspectResp, err := cli.ContainerExecInspect(ctx, execID)
+	return err == nil && inspectResp.Running
+}
+
+// terminateExec asks the exec'd process to stop with SIGTERM and kills it if
+// it is still running after the grace period.
+func terminateExec(ctx context.Context, cli *client.Client, containerID string, execID string, marker string, grace time.Duration) {
+	signalExec(ctx, cli, containerID, marker, "TERM")
+
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		if !execRunning(ctx, cli, execID) {
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	signalExec(ctx, cli, containerID, marker, "KILL")
+}
+
+// forwardSignals relays SIGINT, SIGTERM and SIGHUP received by dockerbx to
+// the exec'd process until the returned stop function is called. A second
+// SIGINT kills the process outright.
+func forwardSignals(ctx context.Context, cli *client.Client, containerID string, execID string, marker string) (stop func()) {
+	sigCh := make(chan os.Signal, 1)
+	signals := make([]os.Signal, 0, len(forwardedSignals))
+	for sig := range forwardedSignals {
+		signals = append(signals, sig)
+	}
+	signal.Notify(sigCh, signals...)
+	done := make(chan struct{})
+
+	go func() {
+		interrupted := false
+		for {
+			select {
+			case sig := <-sigCh:
+				name := forwardedSignals[sig]
+				if sig == syscall.SIGINT {
+					if interrupted {
+						name = "KILL"
+					}
+					interrupted = true
+				}
+				if execRunning(ctx, cli, execID) {
+					signalExec(ctx, cli, containerID, marker, name)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
@@ -0,0 +1,306 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// statsInterval is how often the streaming view is redrawn.
+const statsInterval = time.Second
+
+func StatsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats [container_name...]",
+		Short: "Show live resource usage of dockerbx containers",
+		Long: `Show the CPU, memory, network and block I/O usage of running dockerbx
+containers, all of them unless names are given. The view refreshes every second
+until interrupted; --no-stream prints a single sample instead.`,
+		Run: runStats,
+	}
+
+	cmd.Flags().Bool("no-stream", false, "Print a single sample and exit")
+	cmd.Flags().String("format", "table", "Output format: table or json")
+
+	return cmd
+}
+
+// statsEntry is the resource usage of one container at one point in time.
+type statsEntry struct {
+	Name          string  `json:"name"`
+	ID            string  `json:"id"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryUsage   uint64  `json:"memory_usage"`
+	MemoryLimit   uint64  `json:"memory_limit"`
+	MemoryPercent float64 `json:"memory_percent"`
+	NetworkRx     uint64  `json:"network_rx"`
+	NetworkTx     uint64  `json:"network_tx"`
+	BlockRead     uint64  `json:"block_read"`
+	BlockWrite    uint64  `json:"block_write"`
+	PIDs          uint64  `json:"pids"`
+	Error         string  `json:"error,omitempty"`
+}
+
+type statsTarget struct {
+	ID   string
+	Name string
+}
+
+func runStats(cmd *cobra.Command, args []string) {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		fmt.Printf("Error creating Docker client: %v\n", err)
+		return
+	}
+
+	noStream, _ := cmd.Flags().GetBool("no-stream")
+	format, _ := cmd.Flags().GetString("format")
+	if format != "table" && format != "json" {
+		fmt.Printf("Error: Unknown format %q, use table or json.\n", format)
+		return
+	}
+
+	targets, err := statsTargets(ctx, cli, args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(targets) == 0 {
+		fmt.Println("No running containers owned by \"dockerbx\" found.")
+		return
+	}
+
+	if noStream {
+		entries := make([]statsEntry, len(targets))
+		var wg sync.WaitGroup
+		for i, target := range targets {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				entries[i] = sampleStats(ctx, cli, target)
+			}()
+		}
+		wg.Wait()
+		printStats(entries, format, false)
+		return
+	}
+
+	streamStats(ctx, cli, targets, format)
+}
+
+// statsTargets resolves the containers to watch: the named dockerbx
+// containers, or every running one.
+func statsTargets(ctx context.Context, cli *client.Client, names []string) ([]statsTarget, error) {
+	var targets []statsTarget
+
+	if len(names) == 0 {
+		containers, err := ListDockerBxContainers(ctx, cli, container.ListOptions{
+			Filters: filters.NewArgs(filters.Arg("status", "running")),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			targets = append(targets, statsTarget{ID: c.ID, Name: containerName(c)})
+		}
+		return targets, nil
+	}
+
+	for _, name := range names {
+		containerJSON, err := cli.ContainerInspect(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("container '%s' not found", name)
+		}
+		if containerJSON.Config.Labels["owned_by"] != "dockerbx" {
+			return nil, fmt.Errorf("container '%s' is not a dockerbx container", name)
+		}
+		if !containerJSON.State.Running {
+			return nil, fmt.Errorf("container '%s' is not running", name)
+		}
+		targets = append(targets, statsTarget{ID: containerJSON.ID, Name: strings.TrimPrefix(containerJSON.Name, "/")})
+	}
+	return targets, nil
+}
+
+// sampleStats takes a single sample, for which the daemon also fills in the
+// previous CPU reading so that the CPU percentage can be computed.
+func sampleStats(ctx context.Context, cli *client.Client, target statsTarget) statsEntry {
+	resp, err := cli.ContainerStats(ctx, target.ID, false)
+	if err != nil {
+		return statsEntry{Name: target.Name, ID: target.ID, Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return statsEntry{Name: target.Name, ID: target.ID, Error: err.Error()}
+	}
+	return newStatsEntry(target, stats)
+}
+
+// streamStats follows the stats stream of every container and redraws the
+// latest samples until the context is cancelled.
+func streamStats(ctx context.Context, cli *client.Client, targets []statsTarget, format string) {
+	var mu sync.Mutex
+	entries := make([]statsEntry, len(targets))
+	for i, target := range targets {
+		entries[i] = statsEntry{Name: target.Name, ID: target.ID}
+
+		go func() {
+			resp, err := cli.ContainerStats(ctx, target.ID, true)
+			if err != nil {
+				mu.Lock()
+				entries[i].Error = err.Error()
+				mu.Unlock()
+				return
+			}
+			defer resp.Body.Close()
+
+			decoder := json.NewDecoder(resp.Body)
+			for {
+				var stats container.StatsResponse
+				if err := decoder.Decode(&stats); err != nil {
+					if ctx.Err() == nil {
+						mu.Lock()
+						entries[i].Error = "stopped"
+						mu.Unlock()
+					}
+					return
+				}
+				entry := newStatsEntry(target, stats)
+				mu.Lock()
+				entries[i] = entry
+				mu.Unlock()
+			}
+		}()
+	}
+
+	clearScreen := format == "table" && term.IsTerminal(int(os.Stdout.Fd()))
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		mu.Lock()
+		snapshot := append([]statsEntry{}, entries...)
+		mu.Unlock()
+
+		if clearScreen {
+			fmt.Print("\033[2J\033[H")
+		}
+		printStats(snapshot, format, true)
+	}
+}
+
+// newStatsEntry computes the figures docker stats shows from a raw sample.
+func newStatsEntry(target statsTarget, stats container.StatsResponse) statsEntry {
+	entry := statsEntry{
+		Name:        target.Name,
+		ID:          target.ID,
+		MemoryLimit: stats.MemoryStats.Limit,
+		PIDs:        stats.PidsStats.Current,
+	}
+
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		entry.CPUPercent = cpuDelta / systemDelta * onlineCPUs * 100
+	}
+
+	// page cache is reclaimable, so it is left out as docker stats does
+	entry.MemoryUsage = stats.MemoryStats.Usage
+	if inactive, ok := stats.MemoryStats.Stats["total_inactive_file"]; ok && inactive < entry.MemoryUsage {
+		entry.MemoryUsage -= inactive
+	} else if inactive := stats.MemoryStats.Stats["inactive_file"]; inactive < entry.MemoryUsage {
+		entry.MemoryUsage -= inactive
+	}
+	if entry.MemoryLimit > 0 {
+		entry.MemoryPercent = float64(entry.MemoryUsage) / float64(entry.MemoryLimit) * 100
+	}
+
+	for _, network := range stats.Networks {
+		entry.NetworkRx += network.RxBytes
+		entry.NetworkTx += network.TxBytes
+	}
+
+	for _, io := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(io.Op) {
+		case "read":
+			entry.BlockRead += io.Value
+		case "write":
+			entry.BlockWrite += io.Value
+		}
+	}
+
+	return entry
+}
+
+// printStats prints the samples as a table or JSON. Streamed JSON is written
+// as one array per line.
+func printStats(entries []statsEntry, format string, stream bool) {
+	if format == "json" {
+		var data []byte
+		var err error
+		if stream {
+			data, err = json.Marshal(entries)
+		} else {
+			data, err = json.MarshalIndent(entries, "", "  ")
+		}
+		if err != nil {
+			fmt.Printf("Error marshaling stats: %v\n", err)
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	nameWidth := len("CONTAINER") + 2
+	for _, e := range entries {
+		if len(e.Name)+2 > nameWidth {
+			nameWidth = len(e.Name) + 2
+		}
+	}
+
+	rowFormat := fmt.Sprintf("%%-%ds%%-10s%%-24s%%-10s%%-22s%%-22s%%s\n", nameWidth)
+	fmt.Printf(rowFormat, "CONTAINER", "CPU %", "MEM USAGE / LIMIT", "MEM %", "NET I/O", "BLOCK I/O", "PIDS")
+	for _, e := range entries {
+		if e.Error != "" {
+			fmt.Printf(rowFormat, e.Name, "--", "--", "--", "--", "--", e.Error)
+			continue
+		}
+		fmt.Printf(rowFormat,
+			e.Name,
+			fmt.Sprintf("%.2f%%", e.CPUPercent),
+			units.BytesSize(float64(e.MemoryUsage))+" / "+units.BytesSize(float64(e.MemoryLimit)),
+			fmt.Sprintf("%.2f%%", e.MemoryPercent),
+			units.HumanSizeWithPrecision(float64(e.NetworkRx), 3)+" / "+units.HumanSizeWithPrecision(float64(e.NetworkTx), 3),
+			units.HumanSizeWithPrecision(float64(e.BlockRead), 3)+" / "+units.HumanSizeWithPrecision(float64(e.BlockWrite), 3),
+			fmt.Sprintf("%d", e.PIDs),
+		)
+	}
+}
@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/albertoperdomo2/dockerbx/internal/asciicast"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	mobyterm "github.com/moby/term"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// defaultDetachKeys is the key sequence that detaches from an interactive
+// session, the same as docker attach uses.
+const defaultDetachKeys = "ctrl-p,ctrl-q"
+
+// errDetached is returned by attachExec when the user detached with the
+// detach key sequence, leaving the process running.
+var errDetached = errors.New("detached from exec instance")
+
+// terminalSize returns the size of the host terminal as the [height, width]
+// pair Docker expects, or nil when stdout is not a terminal.
+func terminalSize() *[2]uint {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return nil
+	}
+	return &[2]uint{uint(height), uint(width)}
+}
+
+// terminalEnv returns the variables describing the host terminal to the
+// programs started inside the container.
+func terminalEnv() []string {
+	termName := os.Getenv("TERM")
+	if termName == "" {
+		termName = "xterm"
+	}
+	env := []string{"TERM=" + termName}
+	if colorTerm := os.Getenv("COLORTERM"); colorTerm != "" {
+		env = append(env, "COLORTERM="+colorTerm)
+	}
+	return env
+}
+
+// resizeExec sets the exec's TTY to the current host terminal size and
+// records the change when a recorder is given.
+func resizeExec(ctx context.Context, cli *client.Client, execID string, recorder *asciicast.Recorder) {
+	size := terminalSize()
+	if size == nil {
+		return
+	}
+	cli.ContainerExecResize(ctx, execID, container.ResizeOptions{
+		Height: size[0],
+		Width:  size[1],
+	})
+	if recorder != nil {
+		recorder.Resize(int(size[1]), int(size[0]))
+	}
+}
+
+// monitorTerminalResize resizes the exec's TTY once and then again every
+// time the host terminal changes size, until the returned stop function is
+// called.
+func monitorTerminalResize(ctx context.Context, cli *client.Client, execID string, recorder *asciicast.Recorder) (stop func()) {
+	resizeExec(ctx, cli, execID, nil)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGWINCH)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-sigCh:
+				resizeExec(ctx, cli, execID, recorder)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
+
+// attachOptions controls how attachExec connects the exec to the host.
+type attachOptions struct {
+	// Tty puts the host terminal into raw mode and follows its size.
+	Tty         bool
+	ConsoleSize *[2]uint
+	// Stdin forwards the host's stdin to the process, closing the write
+	// side of the connection when it reaches EOF.
+	Stdin bool
+	// DetachKeys, when typed on a TTY, make attachExec return errDetached
+	// while the process keeps running.
+	DetachKeys []byte
+	// Recorder, if set, receives a copy of the output and the size changes.
+	Recorder *asciicast.Recorder
+}
+
+// attachExec starts the exec with the host's stdio attached and waits for it
+// to finish, returning the exit code of the exec'd process.
+func attachExec(ctx context.Context, cli *client.Client, execID string, opts attachOptions) (int, error) {
+	tty := opts.Tty
+	detachKeys := opts.DetachKeys
+
+	resp, err := cli.ContainerExecAttach(ctx, execID, types.ExecStartCheck{Tty: tty, ConsoleSize: opts.ConsoleSize})
+	if err != nil {
+		return 0, fmt.Errorf("attaching to exec instance: %w", err)
+	}
+	defer resp.Close()
+
+	if tty && term.IsTerminal(int(os.Stdin.Fd())) {
+		oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return 0, fmt.Errorf("setting up terminal: %w", err)
+		}
+		defer term.Restore(int(os.Stdin.Fd()), oldState)
+
+		stopResize := monitorTerminalResize(ctx, cli, execID, opts.Recorder)
+		defer stopResize()
+	}
+
+	var stdout, stderr io.Writer = os.Stdout, os.Stderr
+	if opts.Recorder != nil {
+		stdout = io.MultiWriter(stdout, opts.Recorder)
+		stderr = io.MultiWriter(stderr, opts.Recorder)
+	}
+
+	outputDone := make(chan error, 1)
+	go func() {
+		var err error
+		if tty {
+			_, err = io.Copy(stdout, resp.Reader)
+		} else {
+			_, err = stdcopy.StdCopy(stdout, stderr, resp.Reader)
+		}
+		outputDone <- err
+	}()
+	detached := make(chan struct{})
+	stdinDone := make(chan struct{})
+	stdinReader, err := newStdinReader()
+	if err != nil {
+		return 0, fmt.Errorf("reading stdin: %w", err)
+	}
+	// stop copying stdin once the exec is over, rather than leaving the
+	// goroutine blocked on a read for the rest of the process
+	defer func() {
+		stdinReader.Close()
+		resp.Close()
+		<-stdinDone
+	}()
+	go func() {
+		defer close(stdinDone)
+		defer stdinReader.closeRead()
+		if !opts.Stdin {
+			return
+		}
+		var stdin io.Reader = stdinReader
+		if tty && len(detachKeys) > 0 {
+			stdin = mobyterm.NewEscapeProxy(stdinReader, detachKeys)
+		}
+		_, err := io.Copy(resp.Conn, stdin)
+		if errors.Is(err, errStdinClosed) {
+			return
+		}
+		if errors.As(err, &mobyterm.EscapeError{}) {
+			close(detached)
+			// pass the sequence on so that the daemon detaches too, rather
+			// than closing the process's stdin when the connection goes away
+			resp.Conn.Write(detachKeys)
+			return
+		}
+		// let the process see EOF on its stdin
+		resp.CloseWrite()
+	}()
+
+	// the stream ends when the process exits and the daemon closes the
+	// hijacked connection
+	select {
+	case err := <-outputDone:
+		if err != nil && err != io.EOF {
+			return 0, fmt.Errorf("streaming output: %w", err)
+		}
+	case <-detached:
+		// give the daemon a moment to act on the sequence before the
+		// connection is closed
+		select {
+		case <-outputDone:
+		case <-time.After(2 * time.Second):
+		}
+		return 0, errDetached
+	}
+
+	select {
+	case <-detached:
+		return 0, errDetached
+	default:
+	}
+
+	inspectResp, err := cli.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return 0, fmt.Errorf("inspecting exec instance: %w", err)
+	}
+
+	return inspectResp.ExitCode, nil
+}
+
+// errStdinClosed is returned by a stdinReader once it has been closed.
+var errStdinClosed = errors.New("stdin reader closed")
+
+// stdinReader reads the host's stdin until it is closed. Reads from stdin
+// cannot be interrupted, so it waits for stdin to become readable alongside
+// a pipe that Close hangs up.
+type stdinReader struct {
+	wakeRead  *os.File
+	wakeWrite *os.File
+	closeOnce sync.Once
+}
+
+func newStdinReader() (*stdinReader, error) {
+	wakeRead, wakeWrite, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	return &stdinReader{wakeRead: wakeRead, wakeWrite: wakeWrite}, nil
+}
+
+func (r *stdinReader) Read(p []byte) (int, error) {
+	fds := []unix.PollFd{
+		{Fd: int32(os.Stdin.Fd()), Events: unix.POLLIN},
+		{Fd: int32(r.wakeRead.Fd()), Events: unix.POLLIN},
+	}
+	for {
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if fds[1].Revents != 0 {
+			return 0, errStdinClosed
+		}
+		if fds[0].Revents != 0 {
+			return os.Stdin.Read(p)
+		}
+	}
+}
+
+// Close makes pending and future reads return errStdinClosed.
+func (r *stdinReader) Close() error {
+	r.closeOnce.Do(func() { r.wakeWrite.Close() })
+	return nil
+}
+
+// closeRead releases the reading end of the pipe, once nothing reads.
+func (r *stdinReader) closeRead() {
+	r.wakeRead.Close()
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStdinReader(t *testing.T) {
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer read.Close()
+	defer write.Close()
+
+	stdin := os.Stdin
+	os.Stdin = read
+	defer func() { os.Stdin = stdin }()
+
+	reader, err := newStdinReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.closeRead()
+
+	write.WriteString("input")
+	buf := make([]byte, 16)
+	n, err := reader.Read(buf)
+	if err != nil || string(buf[:n]) != "input" {
+		t.Fatalf("Read = %q, %v, want %q", buf[:n], err, "input")
+	}
+
+	// a read blocked on stdin returns once the reader is closed
+	done := make(chan error)
+	go func() {
+		_, err := io.Copy(io.Discard, reader)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	reader.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errStdinClosed) {
+			t.Errorf("got %v, want errStdinClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Read still blocked after Close")
+	}
+}
@@ -18,10 +18,16 @@ func UpdateCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "update [container_name]",
 		Short: "Update a container's base image and packages",
-		Run:   runUpdate,
+		Long: `Recreate a container from the latest version of its image. The files added,
+changed or deleted in the old container, such as installed packages and edited
+configuration, are carried over to the new one, except in volatile directories
+like /tmp and /var/cache. If some changes cannot be carried over, the update is
+refused unless --discard-changes is given.`,
+		Run: runUpdate,
 	}
 
 	cmd.Flags().BoolP("packages", "p", false, "Update packages within the container")
+	cmd.Flags().Bool("discard-changes", false, "Don't carry over files installed or changed in the old container")
 
 	return cmd
 }
@@ -40,9 +46,10 @@ func runUpdate(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	containerName := config.DefaultName
-	if len(args) > 0 {
-		containerName = args[0]
+	containerName, err := resolveContainerName(ctx, cli, args, config.DefaultName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
 	}
 
 	containerJSON, err := cli.ContainerInspect(ctx, containerName)
@@ -51,6 +58,38 @@ func runUpdate(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	// the new container starts from the image alone, so whatever was
+	// installed or edited in the old one has to be carried over
+	discardChanges, _ := cmd.Flags().GetBool("discard-changes")
+	changes, err := containerChanges(ctx, cli, containerJSON)
+	if err != nil {
+		fmt.Printf("Error reading container changes: %v\n", err)
+		return
+	}
+
+	var archive *changeArchive
+	if len(changes) > 0 && discardChanges {
+		fmt.Printf("Discarding %d changed paths in the old container.\n", len(changes))
+	} else if len(changes) > 0 {
+		fmt.Printf("Saving %d changed paths from the old container...\n", len(changes))
+		archive, err = exportChanges(ctx, cli, containerJSON, changes)
+		if err != nil {
+			fmt.Printf("Error saving container changes: %v\n", err)
+			fmt.Println("Use --discard-changes to update anyway and lose them.")
+			return
+		}
+		defer archive.Close()
+
+		if len(archive.Skipped) > 0 {
+			fmt.Println("Error: These changes cannot be carried over to the new container:")
+			for _, skipped := range archive.Skipped {
+				fmt.Printf("  %s\n", skipped)
+			}
+			fmt.Println("Use --discard-changes to update anyway and lose them.")
+			return
+		}
+	}
+
 	imageName := containerJSON.Config.Image
 
 	fmt.Printf("Pulling latest version of %s...\n", imageName)
@@ -69,6 +108,16 @@ func runUpdate(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if archive != nil {
+		fmt.Println("Restoring changes in the new container...")
+		if err := importChanges(ctx, cli, newContainer.ID, archive); err != nil {
+			fmt.Printf("Error restoring changes: %v\n", err)
+			cli.ContainerRemove(ctx, newContainer.ID, container.RemoveOptions{Force: true})
+			fmt.Printf("The old container '%s' was left untouched.\n", containerName)
+			return
+		}
+	}
+
 	if containerJSON.State.Running {
 		fmt.Println("Stopping the old container...")
 		if err := cli.ContainerStop(ctx, containerName, container.StopOptions{}); err != nil {
@@ -89,6 +138,21 @@ func runUpdate(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if containerJSON.State.Running || (archive != nil && len(archive.Deleted) > 0) {
+		if err := cli.ContainerStart(ctx, newContainer.ID, container.StartOptions{}); err != nil {
+			fmt.Printf("Error starting new container: %v\n", err)
+			return
+		}
+	}
+	if archive != nil && len(archive.Deleted) > 0 {
+		if err := applyDeletions(ctx, cli, newContainer.ID, archive); err != nil {
+			fmt.Printf("Warning: could not remove the files deleted in the old container: %v\n", err)
+		}
+		if !containerJSON.State.Running {
+			cli.ContainerStop(ctx, newContainer.ID, container.StopOptions{})
+		}
+	}
+
 	updatePackages, _ := cmd.Flags().GetBool("packages")
 	if updatePackages {
 		fmt.Println("Updating packages within the container...")
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+)
+
+// containerPathFor maps a host path onto the container through its bind
+// mounts, using the most specific mount that contains it. It reports false
+// when the path is not under any mount.
+func containerPathFor(hostPath string, mounts []types.MountPoint) (string, bool) {
+	hostPath = filepath.Clean(hostPath)
+
+	best, bestLen := "", -1
+	for _, m := range mounts {
+		if m.Type != mount.TypeBind || m.Source == "" || m.Destination == "" {
+			continue
+		}
+		source := filepath.Clean(m.Source)
+
+		rel, err := filepath.Rel(source, hostPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(source) > bestLen {
+			best = path.Join(m.Destination, filepath.ToSlash(rel))
+			bestLen = len(source)
+		}
+	}
+
+	return best, bestLen >= 0
+}
+
+// containerHomeDir asks the container for the home directory of user, or of
+// the container's default user when user is empty.
+func containerHomeDir(ctx context.Context, cli *client.Client, containerID string, user string) string {
+	exitCode, output, err := execInContainer(ctx, cli, containerID, types.ExecConfig{
+		User: user,
+		Cmd:  []string{"/bin/sh", "-c", `echo "$HOME"`},
+	})
+	if err != nil || exitCode != 0 {
+		return ""
+	}
+	return output
+}
+
+// resolveWorkdir returns the working directory for an exec: the explicit
+// override if given, otherwise the container path matching the host working
+// directory, falling back to the user's home inside the container.
+func resolveWorkdir(ctx context.Context, cli *client.Client, containerJSON types.ContainerJSON, user string, override string) string {
+	if override != "" {
+		return override
+	}
+
+	// map through the container's own mounts, which may differ from the
+	// ones in the config
+	if cwd, err := os.Getwd(); err == nil {
+		if workdir, ok := containerPathFor(cwd, containerJSON.Mounts); ok {
+			return workdir
+		}
+	}
+
+	return containerHomeDir(ctx, cli, containerJSON.ID, user)
+}
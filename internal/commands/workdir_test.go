@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+)
+
+func TestContainerPathFor(t *testing.T) {
+	mounts := []types.MountPoint{
+		{Type: mount.TypeBind, Source: "/home/user", Destination: "/home/user"},
+		{Type: mount.TypeBind, Source: "/home/user/src/project", Destination: "/app"},
+		{Type: mount.TypeVolume, Source: "/var/lib/docker/volumes/cache/_data", Destination: "/cache"},
+	}
+
+	tests := []struct {
+		hostPath      string
+		containerPath string
+		ok            bool
+	}{
+		{"/home/user", "/home/user", true},
+		{"/home/user/notes/", "/home/user/notes", true},
+		{"/home/user/src/project", "/app", true},
+		{"/home/user/src/project/cmd", "/app/cmd", true},
+		{"/home/username", "", false},
+		{"/var/lib/docker/volumes/cache/_data", "", false},
+		{"/tmp", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := containerPathFor(tt.hostPath, mounts)
+		if got != tt.containerPath || ok != tt.ok {
+			t.Errorf("containerPathFor(%q) = %q, %v, want %q, %v", tt.hostPath, got, ok, tt.containerPath, tt.ok)
+		}
+	}
+}
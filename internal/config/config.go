@@ -8,19 +8,89 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// Profile holds the settings that can differ between kinds of containers.
+type Profile struct {
+	// Shell is the shell started by enter, such as "zsh" or "/bin/fish".
+	// When empty the shell is detected inside the container.
+	Shell string `yaml:"shell,omitempty"`
+	// StopTimeout is how long stop waits for the container to exit before
+	// killing it, as a duration such as "30s". Docker's default applies
+	// when empty.
+	StopTimeout string `yaml:"stop_timeout,omitempty"`
+	// StopSignal is the signal stop sends first, such as "SIGINT". The
+	// image's stop signal applies when empty.
+	StopSignal string `yaml:"stop_signal,omitempty"`
+	// IdleStop stops running containers that have had no sessions for the
+	// given duration, such as "30m". Containers are never stopped for being
+	// idle when empty.
+	IdleStop string `yaml:"idle_stop,omitempty"`
+}
+
+// HostExec configures the bridge that lets containers run host commands
+// with dockerbx-host-exec.
+type HostExec struct {
+	// Allow lists the host commands containers may run, by name or
+	// absolute path. The bridge is disabled when the list is empty.
+	Allow []string `yaml:"allow,omitempty"`
+}
+
 type Config struct {
 	BaseImage   string        `yaml:"base_image"`
 	DefaultName string        `yaml:"default_name"`
 	Mounts      []mount.Mount `yaml:"mounts"`
+	// Timezone and Locale are propagated into new containers. Leave them
+	// empty (or set "host") to copy the host settings, set "none" to keep
+	// the image defaults, or give an explicit value such as "Europe/Madrid"
+	// or "en_US.UTF-8".
+	Timezone string `yaml:"timezone,omitempty"`
+	Locale   string `yaml:"locale,omitempty"`
+	// DetachKeys is the key sequence that detaches from an interactive
+	// session, e.g. "ctrl-p,ctrl-q".
+	DetachKeys string `yaml:"detach_keys,omitempty"`
+
+	HostExec HostExec `yaml:"host_exec,omitempty"`
+
+	// Defaults are the profile settings used when a container has no
+	// profile or its profile leaves a setting unset.
+	Defaults Profile            `yaml:",inline"`
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
 }
 
-func LoadConfig() (*Config, error) {
+// Profile returns the settings for the named profile, with unset fields
+// taken from the top-level defaults.
+func (c *Config) Profile(name string) Profile {
+	profile := c.Profiles[name]
+	if profile.Shell == "" {
+		profile.Shell = c.Defaults.Shell
+	}
+	if profile.StopTimeout == "" {
+		profile.StopTimeout = c.Defaults.StopTimeout
+	}
+	if profile.StopSignal == "" {
+		profile.StopSignal = c.Defaults.StopSignal
+	}
+	if profile.IdleStop == "" {
+		profile.IdleStop = c.Defaults.IdleStop
+	}
+	return profile
+}
+
+// Dir returns the directory holding the dockerbx configuration and state.
+func Dir() (string, error) {
 	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".config", "dockerbx"), nil
+}
+
+func LoadConfig() (*Config, error) {
+	configDir, err := Dir()
 	if err != nil {
 		return nil, err
 	}
 
-	configPath := filepath.Join(homeDir, ".config", "dockerbx", "dockerbx.yaml")
+	configPath := filepath.Join(configDir, "dockerbx.yaml")
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Export is a container binary made available on the host through a shim
+// script created by export-bin.
+type Export struct {
+	Container string `yaml:"container" json:"container"`
+	Binary    string `yaml:"binary" json:"binary"`
+	Shim      string `yaml:"shim" json:"shim"`
+}
+
+func exportsPath() (string, error) {
+	configDir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "exports.yaml"), nil
+}
+
+// LoadExports reads the manifest of exported binaries. A missing manifest
+// means nothing has been exported yet.
+func LoadExports() ([]Export, error) {
+	path, err := exportsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var exports []Export
+	if err := yaml.Unmarshal(data, &exports); err != nil {
+		return nil, err
+	}
+
+	return exports, nil
+}
+
+// SaveExports writes the manifest of exported binaries.
+func SaveExports(exports []Export) error {
+	path, err := exportsPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(exports)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
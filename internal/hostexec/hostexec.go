@@ -0,0 +1,403 @@
+// Package hostexec implements the bridge that lets programs inside dockerbx
+// containers run allowed commands on the host.
+//
+// The container side is a shell script, so the protocol only needs socat and
+// base64. A call uses three connections to the Unix socket, each starting
+// with a request line:
+//
+//	EXEC           followed by "CWD <base64>" and "ARG <base64>" lines and
+//	               "END"; the reply starts with an "ID <id>" line naming the
+//	               call, the rest of the stream is the command's stdin and the
+//	               rest of the reply its stdout
+//	STDERR <id>    the reply is the command's stderr
+//	STATUS <id>    the reply is the exit status once the command has finished
+//
+// Call IDs are random and chosen by the host, so that a container sharing the
+// socket cannot claim the output of another container's call.
+package hostexec
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ContainerDir is where the socket directory is mounted in containers.
+	ContainerDir = "/run/dockerbx"
+	// SocketName is the name of the socket inside the socket directory.
+	SocketName = "host-exec.sock"
+	// HelperPath is where the helper script is installed in containers.
+	HelperPath = "/usr/local/bin/dockerbx-host-exec"
+
+	exitNotAllowed = 126
+	exitNotFound   = 127
+	exitFailed     = 125
+
+	// streamWait bounds how long a call waits for its other connections.
+	streamWait = 10 * time.Second
+	// statusKeep is how long an unclaimed exit status is kept.
+	statusKeep = time.Minute
+)
+
+// Helper is the script installed in containers as dockerbx-host-exec.
+const Helper = `#!/bin/sh
+# dockerbx-host-exec: run a command on the host through the dockerbx bridge.
+sock="${DOCKERBX_HOST_EXEC_SOCKET:-` + ContainerDir + "/" + SocketName + `}"
+if [ $# -eq 0 ]; then
+	echo "usage: dockerbx-host-exec command [args...]" >&2
+	exit 2
+fi
+if ! command -v socat >/dev/null 2>&1; then
+	echo "dockerbx-host-exec: socat is not installed" >&2
+	exit 125
+fi
+if [ ! -S "$sock" ]; then
+	echo "dockerbx-host-exec: the host is not listening, run dockerbx enter or dockerbx host-exec-agent" >&2
+	exit 125
+fi
+addr="UNIX-CONNECT:$sock"
+enc() { printf '%s' "$1" | base64 | tr -d '\n'; }
+# a terminal on stdin is not forwarded, the host command would wait on it
+[ -t 0 ] && exec </dev/null
+{
+	printf 'EXEC\n'
+	printf 'CWD %s\n' "$(enc "$(pwd)")"
+	for arg in "$@"; do
+		printf 'ARG %s\n' "$(enc "$arg")"
+	done
+	printf 'END\n'
+	cat
+} | socat -t 2147483647 - "$addr" | {
+	IFS= read -r reply
+	case "$reply" in
+	"ID "*) id=${reply#ID } ;;
+	*) echo "dockerbx-host-exec: invalid reply from the host" >&2; exit 125 ;;
+	esac
+	printf 'STDERR %s\n' "$id" | socat -t 2147483647 - "$addr" >&2 &
+	errpid=$!
+	cat
+	wait "$errpid"
+	status=$(printf 'STATUS %s\n' "$id" | socat -t 2147483647 - "$addr")
+	exit "${status:-125}"
+}
+`
+
+// Mount maps a host directory onto a directory inside the containers.
+type Mount struct {
+	Source string
+	Target string
+}
+
+// Server runs host commands on behalf of containers.
+type Server struct {
+	// Allow lists the commands that may be run, by name or absolute path.
+	Allow []string
+	// Mounts translate the container working directory to the host.
+	Mounts []Mount
+	// Log, if set, receives a line for every command run or refused.
+	Log io.Writer
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call tracks one command across its three connections.
+type call struct {
+	stderr chan net.Conn
+	done   chan struct{}
+	status int
+}
+
+// SocketDir returns the host directory holding the bridge socket.
+func SocketDir(configDir string) string {
+	return filepath.Join(configDir, "host-exec")
+}
+
+// Listen binds the socket in dir. It fails if another dockerbx process is
+// already serving it, and replaces a stale socket left behind otherwise.
+//
+// The directory is only accessible to the host user, so that other users on
+// the host cannot run commands as them. In containers, that leaves the socket
+// to root and to users with the host user's UID.
+func Listen(dir string) (net.Listener, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	// MkdirAll leaves the mode of an existing directory alone
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	socketPath := filepath.Join(dir, SocketName)
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return nil, ErrListening
+	}
+	os.Remove(socketPath)
+
+	return net.Listen("unix", socketPath)
+}
+
+// ErrListening is returned by Listen when the socket is already served.
+var ErrListening = errors.New("host-exec bridge already running")
+
+// Serve accepts connections until the listener is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	verb, id, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if verb == "EXEC" {
+		s.exec(conn, reader)
+		return
+	}
+
+	// only calls started by EXEC can be claimed
+	c := s.lookup(id)
+	if c == nil {
+		conn.Close()
+		return
+	}
+
+	switch verb {
+	case "STDERR":
+		select {
+		case c.stderr <- conn:
+		case <-time.After(streamWait):
+			conn.Close()
+		}
+	case "STATUS":
+		select {
+		case <-c.done:
+			if s.forget(id) {
+				fmt.Fprintf(conn, "%d\n", c.status)
+			}
+		case <-time.After(statusKeep):
+		}
+		conn.Close()
+	default:
+		conn.Close()
+	}
+}
+
+// newCall registers a call under a new random ID.
+func (s *Server) newCall() (string, *call, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	id := hex.EncodeToString(b)
+	c := &call{stderr: make(chan net.Conn), done: make(chan struct{})}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.calls == nil {
+		s.calls = map[string]*call{}
+	}
+	s.calls[id] = c
+	return id, c, nil
+}
+
+func (s *Server) lookup(id string) *call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[id]
+}
+
+// forget drops the call, reporting whether it was still registered, so
+// that its status is only handed out once.
+func (s *Server) forget(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.calls[id]
+	delete(s.calls, id)
+	return ok
+}
+
+func (s *Server) exec(conn net.Conn, reader *bufio.Reader) {
+	defer conn.Close()
+
+	args, cwd, err := readRequest(reader)
+	if err != nil {
+		return
+	}
+
+	id, c, err := s.newCall()
+	if err != nil {
+		return
+	}
+	defer func() {
+		close(c.done)
+		time.AfterFunc(statusKeep, func() { s.forget(id) })
+	}()
+
+	if _, err := fmt.Fprintf(conn, "ID %s\n", id); err != nil {
+		c.status = exitFailed
+		return
+	}
+
+	if len(args) == 0 {
+		c.status = exitFailed
+		s.reportError(c, "dockerbx-host-exec: invalid request")
+		return
+	}
+
+	path, ok := s.allowed(args[0])
+	if !ok {
+		c.status = exitNotAllowed
+		s.logf("refused %s", args[0])
+		s.reportError(c, fmt.Sprintf("dockerbx-host-exec: %s is not in the host_exec allow list", args[0]))
+		return
+	}
+
+	// the stderr connection follows the ID, wait for it before starting
+	// so that none of the command's stderr is lost
+	var errOut io.Writer = io.Discard
+	select {
+	case errConn := <-c.stderr:
+		defer errConn.Close()
+		errOut = errConn
+	case <-time.After(streamWait):
+	}
+
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Dir = s.hostDir(cwd)
+	cmd.Stdout = conn
+	cmd.Stderr = errOut
+	stdin, _ := cmd.StdinPipe()
+
+	if err := cmd.Start(); err != nil {
+		c.status = exitNotFound
+		fmt.Fprintf(errOut, "dockerbx-host-exec: %v\n", err)
+		return
+	}
+	s.logf("running %s in %s", strings.Join(args, " "), cmd.Dir)
+
+	go func() {
+		io.Copy(stdin, reader)
+		stdin.Close()
+	}()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		c.status = 0
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		c.status = exitErr.ExitCode()
+	default:
+		c.status = exitFailed
+	}
+}
+
+// reportError sends msg to the call's stderr connection.
+func (s *Server) reportError(c *call, msg string) {
+	select {
+	case errConn := <-c.stderr:
+		fmt.Fprintln(errConn, msg)
+		errConn.Close()
+	case <-time.After(streamWait):
+	}
+}
+
+// allowed resolves the command against the allow list. Commands given by
+// name match entries with the same name or base name; commands given by
+// path must be listed exactly.
+func (s *Server) allowed(name string) (string, bool) {
+	for _, entry := range s.Allow {
+		if entry == name || (!strings.Contains(name, "/") && filepath.Base(entry) == name) {
+			path, err := exec.LookPath(entry)
+			if err != nil {
+				return entry, true
+			}
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// hostDir maps the container working directory to the host, falling back
+// to the home directory when it is outside every mount.
+func (s *Server) hostDir(containerDir string) string {
+	best, bestLen := "", -1
+	for _, m := range s.Mounts {
+		target := strings.TrimSuffix(m.Target, "/")
+		if containerDir != target && !strings.HasPrefix(containerDir, target+"/") {
+			continue
+		}
+		if len(target) > bestLen {
+			best = filepath.Join(m.Source, filepath.FromSlash(strings.TrimPrefix(containerDir, target)))
+			bestLen = len(target)
+		}
+	}
+	if best != "" {
+		if info, err := os.Stat(best); err == nil && info.IsDir() {
+			return best
+		}
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	return homeDir
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, "host-exec: "+format+"\n", args...)
+	}
+}
+
+// readRequest reads the header of an EXEC connection.
+func readRequest(reader *bufio.Reader) ([]string, string, error) {
+	var args []string
+	cwd := ""
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, "", err
+		}
+		key, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if key == "END" {
+			return args, cwd, nil
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, "", err
+		}
+		switch key {
+		case "ARG":
+			args = append(args, string(decoded))
+		case "CWD":
+			cwd = string(decoded)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package hostexec
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not installed")
+	}
+	server := &Server{Allow: []string{"sh", "/opt/tools/open-url"}}
+
+	tests := []struct {
+		name    string
+		path    string
+		allowed bool
+	}{
+		{name: "sh", path: sh, allowed: true},
+		{name: "/opt/tools/open-url", path: "/opt/tools/open-url", allowed: true},
+		{name: "open-url", path: "/opt/tools/open-url", allowed: true},
+		{name: "/tmp/evil/sh", allowed: false},
+		{name: "./sh", allowed: false},
+		{name: "/usr/bin/open-url", allowed: false},
+		{name: "open", allowed: false},
+		{name: "", allowed: false},
+	}
+
+	for _, tt := range tests {
+		path, ok := server.allowed(tt.name)
+		if ok != tt.allowed || path != tt.path {
+			t.Errorf("allowed(%q) = %q, %v, want %q, %v", tt.name, path, ok, tt.path, tt.allowed)
+		}
+	}
+}
+
+func TestHostDir(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	src := t.TempDir()
+	nested := t.TempDir()
+	os.MkdirAll(filepath.Join(src, "project", "sub"), 0755)
+
+	server := &Server{Mounts: []Mount{
+		{Source: src, Target: "/workspace"},
+		{Source: nested, Target: "/workspace/cache/"},
+	}}
+
+	tests := []struct {
+		containerDir string
+		hostDir      string
+	}{
+		{"/workspace", src},
+		{"/workspace/project/sub", filepath.Join(src, "project", "sub")},
+		{"/workspace/cache", nested},
+		{"/workspace/missing", home},
+		{"/workspace-other", home},
+		{"/root", home},
+	}
+
+	for _, tt := range tests {
+		if got := server.hostDir(tt.containerDir); got != tt.hostDir {
+			t.Errorf("hostDir(%q) = %q, want %q", tt.containerDir, got, tt.hostDir)
+		}
+	}
+}
+
+func TestReadRequest(t *testing.T) {
+	enc := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		input string
+		args  []string
+		cwd   string
+		rest  string
+		fails bool
+	}{
+		{
+			input: "CWD " + enc("/workspace") + "\nARG " + enc("ls") + "\nARG " + enc("-la") + "\nEND\nstdin",
+			args:  []string{"ls", "-la"},
+			cwd:   "/workspace",
+			rest:  "stdin",
+		},
+		{
+			input: "ARG " + enc("echo") + "\nARG " + enc("two words\nand a line") + "\nARG \nEND\n",
+			args:  []string{"echo", "two words\nand a line", ""},
+		},
+		{
+			input: "OTHER " + enc("x") + "\nARG " + enc("true") + "\nEND\n",
+			args:  []string{"true"},
+		},
+		{input: "END\n"},
+		{input: "ARG " + enc("ls") + "\n", fails: true},
+		{input: "ARG not base64!\nEND\n", fails: true},
+	}
+
+	for _, tt := range tests {
+		reader := bufio.NewReader(strings.NewReader(tt.input))
+		args, cwd, err := readRequest(reader)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("readRequest(%q) succeeded, want an error", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("readRequest(%q): %v", tt.input, err)
+			continue
+		}
+		rest, _ := io.ReadAll(reader)
+		if !slices.Equal(args, tt.args) || cwd != tt.cwd || string(rest) != tt.rest {
+			t.Errorf("readRequest(%q) = %q, %q, rest %q, want %q, %q, rest %q", tt.input, args, cwd, rest, tt.args, tt.cwd, tt.rest)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	dir := t.TempDir()
+	listener, err := Listen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	server := &Server{Allow: []string{"sh"}}
+	go server.Serve(listener)
+
+	socketPath := filepath.Join(dir, SocketName)
+	dial := func(request string) net.Conn {
+		t.Helper()
+		conn, err := net.Dial("unix", socketPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(conn, request)
+		return conn
+	}
+	call := func(stdin string, args ...string) (string, string, string) {
+		t.Helper()
+		request := "EXEC\n"
+		for _, arg := range args {
+			request += "ARG " + base64.StdEncoding.EncodeToString([]byte(arg)) + "\n"
+		}
+		conn := dial(request + "END\n" + stdin)
+		defer conn.Close()
+		conn.(*net.UnixConn).CloseWrite()
+
+		reader := bufio.NewReader(conn)
+		reply, _ := reader.ReadString('\n')
+		id, ok := strings.CutPrefix(strings.TrimSpace(reply), "ID ")
+		if !ok {
+			t.Fatalf("got reply %q, want an ID", reply)
+		}
+
+		errConn := dial("STDERR " + id + "\n")
+		defer errConn.Close()
+		stdout, _ := io.ReadAll(reader)
+		stderr, _ := io.ReadAll(errConn)
+
+		statusConn := dial("STATUS " + id + "\n")
+		defer statusConn.Close()
+		status, _ := io.ReadAll(statusConn)
+
+		// the status is handed out once
+		again, _ := io.ReadAll(dial("STATUS " + id + "\n"))
+		if len(again) > 0 {
+			t.Errorf("status of %s given twice", id)
+		}
+		return string(stdout), string(stderr), string(status)
+	}
+
+	stdout, stderr, status := call("input\n", "sh", "-c", "echo out; cat; echo err >&2; exit 3")
+	if stdout != "out\ninput\n" || stderr != "err\n" || status != "3\n" {
+		t.Errorf("got stdout %q, stderr %q, status %q", stdout, stderr, status)
+	}
+
+	stdout, stderr, status = call("", "rm", "-rf", "/")
+	if stdout != "" || !strings.Contains(stderr, "not in the host_exec allow list") || status != "126\n" {
+		t.Errorf("refused call: got stdout %q, stderr %q, status %q", stdout, stderr, status)
+	}
+
+	// requests for unknown calls are closed without being registered
+	for _, request := range []string{"STDERR unknown\n", "STATUS unknown\n", "STATUS\n"} {
+		if reply, _ := io.ReadAll(dial(request)); len(reply) > 0 {
+			t.Errorf("%q: got reply %q", request, reply)
+		}
+	}
+	if len(server.calls) > 0 {
+		t.Errorf("calls left registered: %v", server.calls)
+	}
+}